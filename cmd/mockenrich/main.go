@@ -0,0 +1,286 @@
+// cmd/mockenrich/main.go
+//
+// mockenrich — локальный сервер, совместимый с API Agify, Genderize и Nationalize.
+// Используется для разработки и тестов без доступа в интернет:
+//
+//	AGIFY_URL=http://localhost:8090/agify
+//	GENDERIZE_URL=http://localhost:8090/genderize
+//	NATIONALIZE_URL=http://localhost:8090/nationalize
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	seedFile := flag.String("seed", "", "CSV file with name,age,gender,country rows overriding generated results")
+	latency := flag.Duration("latency", 0, "artificial latency added to every response")
+	rate429 := flag.Float64("rate-429", 0, "fraction of requests answered with 429 Too Many Requests (0..1)")
+	rate500 := flag.Float64("rate-500", 0, "fraction of requests answered with 500 Internal Server Error (0..1)")
+	quota := flag.Int("quota", 0, "names allowed per API and quota period, reported in X-Rate-Limit-* headers (0 = unlimited)")
+	quotaPeriod := flag.Duration("quota-period", 24*time.Hour, "quota reset period")
+	flag.Parse()
+
+	seeds := map[string]seed{}
+	if *seedFile != "" {
+		var err error
+		if seeds, err = loadSeeds(*seedFile); err != nil {
+			log.Fatalf("load seeds: %v", err)
+		}
+		log.Printf("loaded %d seeded names from %s", len(seeds), *seedFile)
+	}
+
+	srv := &mockServer{
+		seeds:   seeds,
+		latency: *latency,
+		rate429: *rate429,
+		rate500: *rate500,
+		quota:   newQuotaCounter(*quota, *quotaPeriod),
+	}
+
+	log.Printf("mock enrichment server listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, srv.routes()); err != nil {
+		log.Fatalf("server error: %v", err)
+	}
+}
+
+// seed — заранее заданный результат для имени.
+type seed struct {
+	Age     *int
+	Gender  *string
+	Country *string
+}
+
+// loadSeeds читает CSV вида name,age,gender,country; пустые ячейки означают null.
+// Первая строка считается заголовком, если ее первая ячейка равна "name".
+func loadSeeds(path string) (map[string]seed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 4
+	seeds := map[string]seed{}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return seeds, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(rec[0], "name") {
+			continue
+		}
+
+		var s seed
+		if rec[1] != "" {
+			age, err := strconv.Atoi(rec[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid age %q for %s: %w", rec[1], rec[0], err)
+			}
+			s.Age = &age
+		}
+		if rec[2] != "" {
+			s.Gender = &rec[2]
+		}
+		if rec[3] != "" {
+			s.Country = &rec[3]
+		}
+		seeds[normalize(rec[0])] = s
+	}
+}
+
+type mockServer struct {
+	seeds   map[string]seed
+	latency time.Duration
+	rate429 float64
+	rate500 float64
+	// quota равен nil, если квота не ограничена.
+	quota *quotaCounter
+}
+
+func (s *mockServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/agify/", s.handle("agify", s.agify))
+	mux.HandleFunc("/genderize/", s.handle("genderize", s.genderize))
+	mux.HandleFunc("/nationalize/", s.handle("nationalize", s.nationalize))
+	return mux
+}
+
+// quotaCounter считает имена, запрошенные у каждого API за период, как это делают настоящие API.
+type quotaCounter struct {
+	mu     sync.Mutex
+	limit  int
+	period time.Duration
+	used   map[string]int
+	reset  time.Time
+}
+
+func newQuotaCounter(limit int, period time.Duration) *quotaCounter {
+	if limit <= 0 {
+		return nil
+	}
+	return &quotaCounter{limit: limit, period: period, used: map[string]int{}, reset: time.Now().Add(period)}
+}
+
+// take списывает n имен с квоты api и выставляет заголовки X-Rate-Limit-*.
+// Возвращает false, если квоты не хватает.
+func (q *quotaCounter) take(w http.ResponseWriter, api string, n int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if now := time.Now(); !now.Before(q.reset) {
+		q.used, q.reset = map[string]int{}, now.Add(q.period)
+	}
+	ok := q.used[api]+n <= q.limit
+	if ok {
+		q.used[api] += n
+	}
+
+	w.Header().Set("X-Rate-Limit-Limit", strconv.Itoa(q.limit))
+	w.Header().Set("X-Rate-Limit-Remaining", strconv.Itoa(q.limit-q.used[api]))
+	w.Header().Set("X-Rate-Limit-Reset", strconv.Itoa(int(time.Until(q.reset).Seconds())))
+	return ok
+}
+
+// handle разбирает одиночную (name=) и пакетную (name[]=) формы запроса, добавляет задержку,
+// сбои и учет квоты и отдает ответ в формате соответствующего API.
+func (s *mockServer) handle(api string, build func(name, country string) map[string]interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.latency > 0 {
+			time.Sleep(s.latency)
+		}
+
+		switch p := rand.Float64(); {
+		case p < s.rate429:
+			w.Header().Set("Retry-After", "1")
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Request limit reached"})
+			return
+		case p < s.rate429+s.rate500:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return
+		}
+
+		q := r.URL.Query()
+		if s.quota != nil {
+			n := max(1, len(q["name[]"]))
+			if !s.quota.take(w, api, n) {
+				writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Request limit reached"})
+				return
+			}
+		}
+
+		country := strings.ToUpper(q.Get("country_id"))
+		if names, ok := q["name[]"]; ok {
+			if len(names) > 10 {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Invalid 'name[]' parameter"})
+				return
+			}
+			out := make([]map[string]interface{}, len(names))
+			for i, name := range names {
+				out[i] = build(name, country)
+			}
+			writeJSON(w, http.StatusOK, out)
+			return
+		}
+
+		name := q.Get("name")
+		if name == "" {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Missing 'name' parameter"})
+			return
+		}
+		writeJSON(w, http.StatusOK, build(name, country))
+	}
+}
+
+func (s *mockServer) agify(name, country string) map[string]interface{} {
+	h := hash(name)
+	age := 18 + int(h%63)
+	if sd, ok := s.seeds[normalize(name)]; ok {
+		return withCountry(map[string]interface{}{"name": name, "age": sd.Age, "count": 1000}, country)
+	}
+	return withCountry(map[string]interface{}{"name": name, "age": age, "count": 100 + int(h%10000)}, country)
+}
+
+func (s *mockServer) genderize(name, country string) map[string]interface{} {
+	h := hash(name)
+	if sd, ok := s.seeds[normalize(name)]; ok {
+		return withCountry(map[string]interface{}{"name": name, "gender": sd.Gender, "probability": 1.0, "count": 1000}, country)
+	}
+	gender := "male"
+	if h%2 == 1 {
+		gender = "female"
+	}
+	probability := 0.5 + float64(h%50)/100
+	return withCountry(map[string]interface{}{
+		"name": name, "gender": gender, "probability": probability, "count": 100 + int(h%10000),
+	}, country)
+}
+
+var mockCountries = []string{"RU", "UA", "BY", "KZ", "UZ", "US", "GB", "DE", "FR", "PL"}
+
+func (s *mockServer) nationalize(name, country string) map[string]interface{} {
+	h := hash(name)
+	if sd, ok := s.seeds[normalize(name)]; ok {
+		var list []map[string]interface{}
+		if sd.Country != nil {
+			list = append(list, map[string]interface{}{"country_id": *sd.Country, "probability": 1.0})
+		}
+		return map[string]interface{}{"name": name, "country": list, "count": 1000}
+	}
+
+	n := uint32(len(mockCountries))
+	first := h % n
+	second := (first + 1 + (h/7)%(n-1)) % n
+	p := 0.4 + float64(h%40)/100
+	return map[string]interface{}{
+		"name":  name,
+		"count": 100 + int(h%10000),
+		"country": []map[string]interface{}{
+			{"country_id": mockCountries[first], "probability": p},
+			{"country_id": mockCountries[second], "probability": (1 - p) / 2},
+		},
+	}
+}
+
+// withCountry добавляет country_id в ответ так же, как это делают настоящие API.
+func withCountry(resp map[string]interface{}, country string) map[string]interface{} {
+	if country != "" {
+		resp["country_id"] = country
+	}
+	return resp
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// hash детерминированно отображает имя в число, чтобы результаты повторялись между запусками.
+func hash(name string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(normalize(name)))
+	return h.Sum32()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"effect/internal/service"
+)
+
+// TestMockServer_CompatibleWithProviders проверяет, что провайдеры сервиса понимают ответы mockenrich
+// в одиночной и пакетной формах, а результаты детерминированы.
+func TestMockServer_CompatibleWithProviders(t *testing.T) {
+	age := 33
+	srv := httptest.NewServer((&mockServer{seeds: map[string]seed{"dmitriy": {Age: &age}}}).routes())
+	defer srv.Close()
+
+	e := service.NewCompositeEnricher(
+		&service.AgifyProvider{BaseURL: srv.URL + "/agify"},
+		&service.GenderizeProvider{BaseURL: srv.URL + "/genderize"},
+		&service.NationalizeProvider{BaseURL: srv.URL + "/nationalize"},
+	)
+
+	single, err := e.Enrich(context.Background(), service.Query{Name: "anna"})
+	if err != nil {
+		t.Fatalf("Enrich returned error: %v", err)
+	}
+	if single.Age == nil || single.Gender == nil || single.Nationality == nil {
+		t.Fatalf("expected all fields to be filled, got %+v", single)
+	}
+
+	queries := []service.Query{{Name: "anna"}, {Name: "Dmitriy"}}
+	batch, err := e.EnrichBatch(context.Background(), queries)
+	if err != nil {
+		t.Fatalf("EnrichBatch returned error: %v", err)
+	}
+	if got := batch[queries[0]]; got.Age == nil || *got.Age != *single.Age || *got.Gender != *single.Gender {
+		t.Errorf("expected batch result to match single result, got %+v", got)
+	}
+	if got := batch[queries[1]]; got.Age == nil || *got.Age != 33 {
+		t.Errorf("expected seeded age 33 for Dmitriy, got %+v", got)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"database/sql"
+
+	log "github.com/sirupsen/logrus"
+
+	"effect/internal/config"
+	"effect/internal/service"
+)
+
+// enrichmentChain — собранная цепочка обогащения и ее слои, нужные служебным эндпоинтам.
+type enrichmentChain struct {
+	Enricher  service.Enricher
+	Composite *service.CompositeEnricher
+	// Cache равен nil, если кэш отключен.
+	Cache *service.CachedEnricher
+	// Quotas — квоты включенных провайдеров.
+	Quotas []*service.Quota
+}
+
+// buildEnricher собирает цепочку обогащения согласно конфигурации.
+func buildEnricher(cfg *config.Config, dbConn *sql.DB) *enrichmentChain {
+	chain := &enrichmentChain{}
+
+	var providers []service.Provider
+	if pc := cfg.Agify; pc.Enabled {
+		p := service.NewAgifyProvider(chain.client(pc, "agify"))
+		p.BaseURL, p.APIKey = baseURLOr(pc.BaseURL, p.BaseURL), pc.APIKey
+		providers = append(providers, p)
+	}
+	if pc := cfg.Genderize; pc.Enabled {
+		p := service.NewGenderizeProvider(chain.client(pc, "genderize"))
+		p.BaseURL, p.APIKey = baseURLOr(pc.BaseURL, p.BaseURL), pc.APIKey
+		providers = append(providers, p)
+	}
+	if pc := cfg.Nationalize; pc.Enabled {
+		p := service.NewNationalizeProvider(chain.client(pc, "nationalize"))
+		p.BaseURL, p.APIKey = baseURLOr(pc.BaseURL, p.BaseURL), pc.APIKey
+		providers = append(providers, p)
+	}
+	for _, p := range providers {
+		log.Infof("enrichment provider %s enabled", p.Name())
+	}
+	if len(providers) == 0 {
+		log.Warn("all enrichment providers are disabled")
+	}
+	if cfg.BreakerThreshold > 0 {
+		for i, p := range providers {
+			providers[i] = service.NewBreakerProvider(p, service.NewBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown))
+		}
+		log.Infof("enrichment circuit breaker: threshold=%d cooldown=%s", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	}
+
+	chain.Composite = service.NewCompositeEnricher(providers...)
+	chain.Enricher = chain.Composite
+
+	policy, err := service.ParsePolicy(cfg.EnrichPolicy)
+	if err != nil {
+		log.WithError(err).Warn("falling back to strict enrichment policy")
+		policy = service.PolicyStrict
+	}
+	chain.Composite.Policy = policy
+	chain.Composite.BatchConcurrency = cfg.EnrichBatchConcurrency
+	log.Infof("enrichment policy: %s", policy)
+
+	var cache service.Cache
+	switch cfg.CacheBackend {
+	case "memory":
+		cache = service.NewMemoryCache(cfg.CacheSize, cfg.CacheTTL)
+	case "postgres":
+		cache = service.NewPostgresCache(dbConn, cfg.CacheTTL)
+	case "none":
+	default:
+		log.Warnf("unknown CACHE_BACKEND %q, enrichment cache disabled", cfg.CacheBackend)
+	}
+	if cache != nil {
+		log.Infof("enrichment cache: backend=%s ttl=%s", cfg.CacheBackend, cfg.CacheTTL)
+		chain.Cache = service.NewCachedEnricher(chain.Enricher, cache)
+		chain.Enricher = chain.Cache
+	}
+
+	// срок ограничивает обращения к кэшу и провайдерам, но не словарь: по истечении срока
+	// запрос еще может получить ответ из словаря
+	if cfg.EnrichTimeout > 0 {
+		chain.Enricher = service.NewDeadlineEnricher(chain.Enricher, cfg.EnrichTimeout)
+		log.Infof("enrichment timeout: %s", cfg.EnrichTimeout)
+	}
+
+	// словарь стоит поверх кэша, чтобы резервные ответы не попадали в кэш
+	if cfg.EnrichFallback {
+		dict, err := loadDictionary(cfg.EnrichFallbackFile)
+		if err != nil {
+			log.WithError(err).Error("failed to load fallback dictionary, fallback disabled")
+		} else {
+			dict.MaxLearned = cfg.EnrichFallbackLearnMax
+			chain.Enricher = service.NewFallbackEnricher(chain.Enricher, dict, cfg.EnrichFallbackLearn)
+			log.Infof("enrichment fallback dictionary: %d names", dict.Len())
+		}
+	}
+
+	// страна по умолчанию подставляется до кэша, чтобы ключ кэша учитывал ее
+	if cfg.DefaultCountry != "" {
+		chain.Enricher = service.NewDefaultCountryEnricher(chain.Enricher, cfg.DefaultCountry)
+		log.Infof("enrichment default country: %s", cfg.DefaultCountry)
+	}
+
+	// имена нормализуются до кэша и словаря, чтобы разные написания одного имени
+	// использовали общий ключ кэша и находились в словаре
+	scheme, err := service.ParseTranslitScheme(cfg.NameTranslit)
+	if err != nil {
+		log.WithError(err).Warn("falling back to bgn transliteration")
+		scheme = service.TranslitBGN
+	}
+	chain.Enricher = service.NewNormalizingEnricher(chain.Enricher, scheme)
+	log.Infof("enrichment name transliteration: %s", scheme)
+
+	// пороги применяются поверх кэша, чтобы в кэше хранились исходные ответы провайдеров
+	chain.Enricher = service.NewThresholdEnricher(chain.Enricher, service.Thresholds{
+		MinAgeCount:               cfg.MinAgeCount,
+		MinGenderProbability:      cfg.MinGenderProbability,
+		MinGenderCount:            cfg.MinGenderCount,
+		MinNationalityProbability: cfg.MinNationalityProbability,
+		MinNationalityCount:       cfg.MinNationalityCount,
+	})
+
+	return chain
+}
+
+// client создает HTTP-клиент провайдера с учетом его квоты.
+func (c *enrichmentChain) client(pc config.ProviderConfig, provider string) *service.APIClient {
+	client := service.NewAPIClient(pc.Timeout)
+	client.Quota = service.NewQuota(provider)
+	c.Quotas = append(c.Quotas, client.Quota)
+	return client
+}
+
+// loadDictionary загружает резервный словарь из файла path или встроенный, если path пуст.
+func loadDictionary(path string) (*service.Dictionary, error) {
+	if path == "" {
+		return service.DefaultDictionary()
+	}
+	return service.LoadDictionaryFile(path)
+}
+
+// baseURLOr возвращает url, а если он не задан — def.
+func baseURLOr(url, def string) string {
+	if url == "" {
+		return def
+	}
+	return url
+}
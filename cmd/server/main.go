@@ -1,23 +1,33 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
 
 	"effect/internal/config"
-	"effect/internal/db"
 	"effect/internal/handler"
 	"effect/internal/middleware"
+	"effect/internal/queue"
 )
 
+// shutdownTimeout — сколько ждать завершения активных запросов при остановке.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	_ = godotenv.Load()
 
 	cfg := config.Load()
+	storage := flag.String("storage", cfg.Storage, "person storage: postgres or memory")
+	flag.Parse()
 
 	log.SetFormatter(&log.TextFormatter{
 		FullTimestamp: true,
@@ -26,50 +36,69 @@ func main() {
 	log.SetLevel(cfg.LogLevel)
 	log.Infof("starting service on port %d, log level=%s", cfg.Port, cfg.LogLevel)
 
-	dbConn, err := db.NewDB(cfg.DatabaseURL)
+	repo, dbConn, err := openStorage(cfg, *storage)
 	if err != nil {
-		log.Fatalf("db connect: %v", err)
+		log.Fatal(err)
 	}
-	log.Debug("database connection established")
+	log.Infof("person storage: %s", *storage)
 
-	h := &handler.PersonHandler{DB: dbConn}
+	chain := buildEnricher(cfg, dbConn)
+	h := handler.NewPersonHandler(repo, chain.Enricher)
+	h.Async = cfg.EnrichAsync
+	admin := handler.NewAdminHandler(chain.Cache, chain.Composite, chain.Quotas)
 
-	mux := http.NewServeMux()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	logged := func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			log.Infof("%s %s", r.Method, r.URL.Path)
-			next(w, r)
-		}
+	// воркеры очереди нужны и при синхронном обогащении: в очередь ставятся записи,
+	// которые не удалось обогатить заново при смене имени
+	if dbConn != nil {
+		h.QueueOnError = true
+		pool := queue.NewPool(dbConn, chain.Enricher, queue.Options{
+			Workers:      cfg.EnrichWorkers,
+			PollInterval: cfg.EnrichPollInterval,
+			MaxAttempts:  cfg.EnrichMaxAttempts,
+			RetryBackoff: cfg.EnrichRetryBackoff,
+			Lease:        cfg.EnrichLease,
+		})
+		go pool.Run(ctx)
 	}
 
-	mux.HandleFunc("/persons", logged(func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			h.Create(w, r)
-		case http.MethodGet:
-			h.GetAll(w, r)
-		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		}
-	}))
-
-	mux.HandleFunc("/persons/", logged(func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPut:
-			h.Update(w, r)
-		case http.MethodDelete:
-			h.Delete(w, r)
-		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		}
-	}))
+	if cfg.ReenrichInterval > 0 {
+		refresher := queue.NewRefresher(dbConn, chain.Enricher, queue.RefreshOptions{
+			Interval:   cfg.ReenrichInterval,
+			MaxAge:     cfg.ReenrichMaxAge,
+			BatchSize:  cfg.ReenrichBatchSize,
+			MaxPerRun:  cfg.ReenrichMaxPerRun,
+			BatchPause: cfg.ReenrichBatchPause,
+			Lease:      cfg.EnrichLease,
+		})
+		go refresher.Run(ctx)
+	}
+
+	mux := http.NewServeMux()
+	h.Register(mux)
+	admin.Register(mux)
 
 	handlerWithCORS := middleware.CORS(mux)
 
-	addr := fmt.Sprintf(":%d", cfg.Port)
-	log.Infof("listening on %s", addr)
-	if err := http.ListenAndServe(addr, handlerWithCORS); err != nil {
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: handlerWithCORS,
+	}
+
+	go func() {
+		<-ctx.Done()
+		log.Info("shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.WithError(err).Error("server shutdown failed")
+		}
+	}()
+
+	log.Infof("listening on %s", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("server error: %v", err)
 	}
 }
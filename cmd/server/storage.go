@@ -0,0 +1,42 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"effect/internal/config"
+	"effect/internal/db"
+	"effect/internal/repository"
+)
+
+// openStorage открывает хранилище записей storage. Для memory соединение с БД не создается
+// (возвращается nil), а зависящие от PostgreSQL функции отключаются в cfg.
+func openStorage(cfg *config.Config, storage string) (repository.PersonRepository, *sql.DB, error) {
+	switch storage {
+	case "postgres":
+		dbConn, err := db.NewDB(cfg.DatabaseURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("db connect: %w", err)
+		}
+		log.Debug("database connection established")
+		return repository.NewPostgresRepository(dbConn), dbConn, nil
+	case "memory":
+		log.Warn("using in-memory storage: data is lost on restart")
+		if cfg.EnrichAsync {
+			log.Warn("asynchronous enrichment requires postgres storage, enriching synchronously")
+			cfg.EnrichAsync = false
+		}
+		if cfg.ReenrichInterval > 0 {
+			log.Warn("re-enrichment requires postgres storage, disabled")
+			cfg.ReenrichInterval = 0
+		}
+		if cfg.CacheBackend == "postgres" {
+			log.Warn("postgres enrichment cache requires postgres storage, using memory cache")
+			cfg.CacheBackend = "memory"
+		}
+		return repository.NewMemoryRepository(), nil, nil
+	}
+	return nil, nil, fmt.Errorf("unknown storage %q, expected postgres or memory", storage)
+}
@@ -0,0 +1,187 @@
+// Package audit хранит историю значений обогащаемых атрибутов записей persons
+// вместе с их происхождением (таблица person_field_history).
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"effect/internal/model"
+	"effect/internal/service"
+)
+
+// Execer — общий интерфейс *sql.DB и *sql.Tx для записи.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Querier — общий интерфейс *sql.DB и *sql.Tx для чтения.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Record добавляет в историю текущие значения атрибутов p, для которых передано происхождение.
+func Record(ctx context.Context, ex Execer, p model.Person, prov model.Provenance) error {
+	values := FieldValues(p)
+	for field, fp := range prov {
+		value, ok := values[field]
+		if !ok {
+			continue
+		}
+		if _, err := ex.ExecContext(ctx, `
+			INSERT INTO person_field_history
+				(person_id, field, value, source, provider, response_id, raw, inferred, enriched_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9)`,
+			p.ID, field, value, fp.Source, fp.Provider, fp.ResponseID, rawOrNil(fp.Raw), fp.Inferred, fp.EnrichedAt,
+		); err != nil {
+			return fmt.Errorf("record %s history for id=%d: %w", field, p.ID, err)
+		}
+	}
+	return nil
+}
+
+// provenanceColumns — столбцы person_field_history в порядке, ожидаемом scanProvenance.
+const provenanceColumns = `value, source, COALESCE(provider, ''), COALESCE(response_id, ''), raw, inferred, enriched_at`
+
+// scanProvenance читает строку, выбранную по provenanceColumns; prefix — предшествующие им столбцы.
+func scanProvenance(rows *sql.Rows, prefix ...interface{}) (model.FieldProvenance, error) {
+	var (
+		fp  model.FieldProvenance
+		raw []byte
+	)
+	dest := append(prefix, &fp.Value, &fp.Source, &fp.Provider, &fp.ResponseID, &raw, &fp.Inferred, &fp.EnrichedAt)
+	if err := rows.Scan(dest...); err != nil {
+		return fp, err
+	}
+	fp.Raw = raw
+	return fp, nil
+}
+
+// Current возвращает последнюю запись истории по каждому атрибуту записи personID.
+func Current(ctx context.Context, q Querier, personID int) (model.Provenance, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT DISTINCT ON (field) field, `+provenanceColumns+`
+		FROM person_field_history
+		WHERE person_id = $1
+		ORDER BY field, id DESC`, personID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prov := model.Provenance{}
+	for rows.Next() {
+		var field string
+		fp, err := scanProvenance(rows, &field)
+		if err != nil {
+			return nil, err
+		}
+		prov[field] = fp
+	}
+	return prov, rows.Err()
+}
+
+// History возвращает все изменения атрибутов записи personID в порядке их записи.
+func History(ctx context.Context, q Querier, personID int) ([]model.HistoryEntry, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT field, `+provenanceColumns+`
+		FROM person_field_history
+		WHERE person_id = $1
+		ORDER BY id`, personID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []model.HistoryEntry{}
+	for rows.Next() {
+		var e model.HistoryEntry
+		if e.FieldProvenance, err = scanProvenance(rows, &e.Field); err != nil {
+			return nil, err
+		}
+		history = append(history, e)
+	}
+	return history, rows.Err()
+}
+
+// LatestInferred возвращает последнее предсказанное (не заданное вручную) значение атрибута field.
+func LatestInferred(ctx context.Context, q Querier, personID int, field string) (model.FieldProvenance, bool, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+provenanceColumns+`
+		FROM person_field_history
+		WHERE person_id = $1 AND field = $2 AND inferred
+		ORDER BY id DESC
+		LIMIT 1`, personID, field,
+	)
+	if err != nil {
+		return model.FieldProvenance{}, false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.FieldProvenance{}, false, rows.Err()
+	}
+	fp, err := scanProvenance(rows)
+	return fp, err == nil, err
+}
+
+// Unlocked возвращает копию происхождения только тех атрибутов, которые не заблокированы вручную.
+func Unlocked(prov model.Provenance, locked []string) model.Provenance {
+	out := make(model.Provenance, len(prov))
+	for field, fp := range prov {
+		if !slices.Contains(locked, field) {
+			out[field] = fp
+		}
+	}
+	return out
+}
+
+// FieldValues возвращает обогащаемые атрибуты записи в текстовом виде.
+func FieldValues(p model.Person) map[string]*string {
+	var age *string
+	if p.Age != nil {
+		s := strconv.Itoa(*p.Age)
+		age = &s
+	}
+	return map[string]*string{
+		service.FieldAge:         age,
+		service.FieldGender:      p.Gender,
+		service.FieldNationality: p.Nationality,
+	}
+}
+
+// SetFieldValue записывает текстовое значение атрибута field в запись p (обратное FieldValues).
+func SetFieldValue(p *model.Person, field string, value *string) error {
+	switch field {
+	case service.FieldAge:
+		if value == nil {
+			p.Age = nil
+			return nil
+		}
+		age, err := strconv.Atoi(*value)
+		if err != nil {
+			return fmt.Errorf("invalid age %q: %w", *value, err)
+		}
+		p.Age = &age
+	case service.FieldGender:
+		p.Gender = value
+	case service.FieldNationality:
+		p.Nationality = value
+	default:
+		return fmt.Errorf("unknown field %q", field)
+	}
+	return nil
+}
+
+// rawOrNil возвращает nil для пустого ответа, чтобы в столбец JSONB записался NULL.
+func rawOrNil(raw []byte) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return raw
+}
@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"testing"
+
+	"effect/internal/model"
+)
+
+// TestUnlocked проверяет, что происхождение заблокированных вручную атрибутов отбрасывается.
+func TestUnlocked(t *testing.T) {
+	prov := model.Provenance{
+		"age":    {Source: model.SourceAPI},
+		"gender": {Source: model.SourceAPI},
+	}
+
+	out := Unlocked(prov, []string{"gender"})
+	if _, ok := out["gender"]; ok || len(out) != 1 {
+		t.Errorf("expected only age provenance, got %+v", out)
+	}
+	if len(prov) != 2 {
+		t.Error("expected source provenance to stay unchanged")
+	}
+}
+
+// TestSetFieldValue проверяет обратное преобразование текстовых значений атрибутов.
+func TestSetFieldValue(t *testing.T) {
+	var p model.Person
+	age := "42"
+	if err := SetFieldValue(&p, "age", &age); err != nil || p.Age == nil || *p.Age != 42 {
+		t.Fatalf("expected age 42, got %v (err=%v)", p.Age, err)
+	}
+	if v := FieldValues(p)["age"]; v == nil || *v != "42" {
+		t.Errorf("expected FieldValues to round-trip age, got %v", v)
+	}
+	if err := SetFieldValue(&p, "height", &age); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
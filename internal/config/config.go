@@ -3,16 +3,87 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
 )
 
+// ProviderConfig — настройки одного внешнего API обогащения.
+type ProviderConfig struct {
+	// BaseURL — адрес API; пустое значение означает публичный адрес по умолчанию.
+	BaseURL string
+	APIKey  string
+	Timeout time.Duration
+	Enabled bool
+}
+
 type Config struct {
 	DatabaseURL   string
 	MigrationsDir string
 	LogLevel      log.Level
 	Port          int
+	// Storage — хранилище записей: postgres или memory (без БД, данные теряются при перезапуске).
+	Storage string
+
+	// CacheBackend — хранилище кэша обогащения: memory, postgres или none.
+	CacheBackend string
+	CacheTTL     time.Duration
+	CacheSize    int
+
+	// BreakerThreshold — число ошибок подряд, после которого провайдер отключается на BreakerCooldown.
+	// Значение 0 отключает автоматический выключатель.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// EnrichPolicy — политика обработки ошибок провайдеров: strict, best-effort или skip.
+	EnrichPolicy string
+
+	// EnrichAsync включает асинхронное обогащение через очередь в PostgreSQL.
+	EnrichAsync        bool
+	EnrichWorkers      int
+	EnrichMaxAttempts  int
+	EnrichRetryBackoff time.Duration
+	EnrichPollInterval time.Duration
+	// EnrichLease — срок, на который воркер очереди или повторного обогащения захватывает записи;
+	// должен превышать EnrichTimeout, иначе запись может захватить другой воркер.
+	EnrichLease time.Duration
+	// EnrichTimeout — общий срок обогащения одной записи или пакета; 0 отключает ограничение.
+	EnrichTimeout time.Duration
+	// EnrichBatchConcurrency ограничивает число одновременных пакетных запросов к провайдерам.
+	EnrichBatchConcurrency int
+	// DefaultCountry — код страны, который передается провайдерам, если у записи нет country_hint.
+	DefaultCountry string
+	// NameTranslit — схема транслитерации имен перед обогащением: bgn, passport или none.
+	NameTranslit string
+
+	// EnrichFallback включает локальный словарь имен на случай недоступности внешних API.
+	EnrichFallback bool
+	// EnrichFallbackFile — путь к CSV-словарю; пустое значение означает встроенный словарь.
+	EnrichFallbackFile string
+	// EnrichFallbackLearn включает пополнение словаря успешными ответами API.
+	EnrichFallbackLearn bool
+	// EnrichFallbackLearnMax ограничивает число имен, которыми пополняется словарь.
+	EnrichFallbackLearnMax int
+
+	// ReenrichInterval — период повторного обогащения устаревших записей; 0 отключает его.
+	ReenrichInterval time.Duration
+	// ReenrichMaxAge — возраст обогащения, после которого запись считается устаревшей.
+	ReenrichMaxAge     time.Duration
+	ReenrichBatchSize  int
+	ReenrichMaxPerRun  int
+	ReenrichBatchPause time.Duration
+
+	Agify       ProviderConfig
+	Genderize   ProviderConfig
+	Nationalize ProviderConfig
+
+	// Пороги достоверности обогащенных значений; 0 отключает проверку.
+	MinAgeCount               int
+	MinGenderProbability      float64
+	MinGenderCount            int
+	MinNationalityProbability float64
+	MinNationalityCount       int
 }
 
 // Load загружает конфигурацию из переменных окружения.
@@ -35,11 +106,190 @@ func Load() *Config {
 		port = 8080
 	}
 
+	// Получаем хранилище записей из переменной окружения STORAGE, по умолчанию postgres
+	storage := os.Getenv("STORAGE")
+	if storage == "" {
+		storage = "postgres"
+	}
+
+	// Получаем настройки кэша обогащения из переменных окружения CACHE_BACKEND, CACHE_TTL и CACHE_SIZE
+	// По умолчанию используется кэш в памяти на 10000 имен со сроком жизни 24 часа
+	cacheBackend := os.Getenv("CACHE_BACKEND")
+	if cacheBackend == "" {
+		cacheBackend = "memory"
+	}
+	cacheTTL, err := time.ParseDuration(os.Getenv("CACHE_TTL"))
+	if err != nil {
+		cacheTTL = 24 * time.Hour
+	}
+	cacheSize, err := strconv.Atoi(os.Getenv("CACHE_SIZE"))
+	if err != nil || cacheSize <= 0 {
+		cacheSize = 10000
+	}
+
+	// Получаем настройки автоматического выключателя из BREAKER_FAILURE_THRESHOLD и BREAKER_COOLDOWN
+	// По умолчанию провайдер отключается на 30 секунд после 5 ошибок подряд
+	breakerThreshold, err := strconv.Atoi(os.Getenv("BREAKER_FAILURE_THRESHOLD"))
+	if err != nil || breakerThreshold < 0 {
+		breakerThreshold = 5
+	}
+	breakerCooldown, err := time.ParseDuration(os.Getenv("BREAKER_COOLDOWN"))
+	if err != nil {
+		breakerCooldown = 30 * time.Second
+	}
+
+	// Получаем политику обогащения из переменной окружения ENRICH_POLICY, по умолчанию strict
+	enrichPolicy := os.Getenv("ENRICH_POLICY")
+	if enrichPolicy == "" {
+		enrichPolicy = "strict"
+	}
+
+	// Получаем настройки обогащения из ENRICH_ASYNC, ENRICH_WORKERS, ENRICH_MAX_ATTEMPTS, ENRICH_RETRY_BACKOFF,
+	// ENRICH_POLL_INTERVAL, ENRICH_LEASE, ENRICH_TIMEOUT и ENRICH_BATCH_CONCURRENCY. По умолчанию обогащение
+	// выполняется синхронно и ограничено 15 секундами
+	enrichAsync, _ := strconv.ParseBool(os.Getenv("ENRICH_ASYNC"))
+	enrichWorkers, err := strconv.Atoi(os.Getenv("ENRICH_WORKERS"))
+	if err != nil || enrichWorkers <= 0 {
+		enrichWorkers = 4
+	}
+	enrichMaxAttempts, err := strconv.Atoi(os.Getenv("ENRICH_MAX_ATTEMPTS"))
+	if err != nil || enrichMaxAttempts <= 0 {
+		enrichMaxAttempts = 5
+	}
+	enrichRetryBackoff, err := time.ParseDuration(os.Getenv("ENRICH_RETRY_BACKOFF"))
+	if err != nil {
+		enrichRetryBackoff = 30 * time.Second
+	}
+	enrichPollInterval, err := time.ParseDuration(os.Getenv("ENRICH_POLL_INTERVAL"))
+	if err != nil {
+		enrichPollInterval = 2 * time.Second
+	}
+	enrichLease, err := time.ParseDuration(os.Getenv("ENRICH_LEASE"))
+	if err != nil || enrichLease <= 0 {
+		enrichLease = 5 * time.Minute
+	}
+	enrichTimeout, err := time.ParseDuration(os.Getenv("ENRICH_TIMEOUT"))
+	if err != nil || enrichTimeout < 0 {
+		enrichTimeout = 15 * time.Second
+	}
+	enrichBatchConcurrency, err := strconv.Atoi(os.Getenv("ENRICH_BATCH_CONCURRENCY"))
+	if err != nil || enrichBatchConcurrency <= 0 {
+		enrichBatchConcurrency = 4
+	}
+
+	// Получаем схему транслитерации имен из переменной окружения NAME_TRANSLIT, по умолчанию bgn
+	nameTranslit := os.Getenv("NAME_TRANSLIT")
+	if nameTranslit == "" {
+		nameTranslit = "bgn"
+	}
+
+	// Получаем настройки резервного словаря из ENRICH_FALLBACK, ENRICH_FALLBACK_FILE, ENRICH_FALLBACK_LEARN
+	// и ENRICH_FALLBACK_LEARN_MAX. По умолчанию используется встроенный словарь без пополнения ответами API;
+	// при включенном пополнении запоминается не больше 10000 новых имен
+	enrichFallback, err := strconv.ParseBool(os.Getenv("ENRICH_FALLBACK"))
+	if err != nil {
+		enrichFallback = true
+	}
+	enrichFallbackLearn, _ := strconv.ParseBool(os.Getenv("ENRICH_FALLBACK_LEARN"))
+	enrichFallbackLearnMax, err := strconv.Atoi(os.Getenv("ENRICH_FALLBACK_LEARN_MAX"))
+	if err != nil || enrichFallbackLearnMax <= 0 {
+		enrichFallbackLearnMax = 10000
+	}
+
+	// Получаем настройки повторного обогащения из REENRICH_INTERVAL, REENRICH_MAX_AGE, REENRICH_BATCH_SIZE,
+	// REENRICH_MAX_PER_RUN и REENRICH_BATCH_PAUSE. По умолчанию повторное обогащение отключено
+	reenrichInterval, _ := time.ParseDuration(os.Getenv("REENRICH_INTERVAL"))
+	reenrichMaxAge, err := time.ParseDuration(os.Getenv("REENRICH_MAX_AGE"))
+	if err != nil || reenrichMaxAge <= 0 {
+		reenrichMaxAge = 30 * 24 * time.Hour
+	}
+	reenrichBatchSize, err := strconv.Atoi(os.Getenv("REENRICH_BATCH_SIZE"))
+	if err != nil || reenrichBatchSize <= 0 {
+		reenrichBatchSize = 10
+	}
+	reenrichMaxPerRun, err := strconv.Atoi(os.Getenv("REENRICH_MAX_PER_RUN"))
+	if err != nil || reenrichMaxPerRun <= 0 {
+		reenrichMaxPerRun = 100
+	}
+	reenrichBatchPause, err := time.ParseDuration(os.Getenv("REENRICH_BATCH_PAUSE"))
+	if err != nil {
+		reenrichBatchPause = time.Second
+	}
+
+	// Получаем пороги достоверности из MIN_AGE_COUNT, MIN_GENDER_PROBABILITY, MIN_GENDER_COUNT
+	// MIN_NATIONALITY_PROBABILITY и MIN_NATIONALITY_COUNT. Некорректные или отсутствующие значения отключают проверку
+	minAgeCount, _ := strconv.Atoi(os.Getenv("MIN_AGE_COUNT"))
+	minGenderProbability, _ := strconv.ParseFloat(os.Getenv("MIN_GENDER_PROBABILITY"), 64)
+	minGenderCount, _ := strconv.Atoi(os.Getenv("MIN_GENDER_COUNT"))
+	minNationalityProbability, _ := strconv.ParseFloat(os.Getenv("MIN_NATIONALITY_PROBABILITY"), 64)
+	minNationalityCount, _ := strconv.Atoi(os.Getenv("MIN_NATIONALITY_COUNT"))
+
 	// Возвращаем структуру Config с загруженными значениями
 	return &Config{
 		DatabaseURL:   os.Getenv("DATABASE_URL"),
 		MigrationsDir: os.Getenv("MIGRATIONS_DIR"),
 		LogLevel:      lvl,
 		Port:          port,
+		Storage:       storage,
+		CacheBackend:  cacheBackend,
+		CacheTTL:      cacheTTL,
+		CacheSize:     cacheSize,
+
+		BreakerThreshold: breakerThreshold,
+		BreakerCooldown:  breakerCooldown,
+		EnrichPolicy:     enrichPolicy,
+
+		EnrichAsync:        enrichAsync,
+		EnrichWorkers:      enrichWorkers,
+		EnrichMaxAttempts:  enrichMaxAttempts,
+		EnrichRetryBackoff: enrichRetryBackoff,
+		EnrichPollInterval: enrichPollInterval,
+		EnrichLease:        enrichLease,
+
+		EnrichTimeout:          enrichTimeout,
+		EnrichBatchConcurrency: enrichBatchConcurrency,
+		DefaultCountry:         os.Getenv("DEFAULT_COUNTRY"),
+		NameTranslit:           nameTranslit,
+
+		EnrichFallback:         enrichFallback,
+		EnrichFallbackFile:     os.Getenv("ENRICH_FALLBACK_FILE"),
+		EnrichFallbackLearn:    enrichFallbackLearn,
+		EnrichFallbackLearnMax: enrichFallbackLearnMax,
+
+		ReenrichInterval:   reenrichInterval,
+		ReenrichMaxAge:     reenrichMaxAge,
+		ReenrichBatchSize:  reenrichBatchSize,
+		ReenrichMaxPerRun:  reenrichMaxPerRun,
+		ReenrichBatchPause: reenrichBatchPause,
+
+		Agify:       loadProvider("AGIFY"),
+		Genderize:   loadProvider("GENDERIZE"),
+		Nationalize: loadProvider("NATIONALIZE"),
+
+		MinAgeCount:               minAgeCount,
+		MinGenderProbability:      minGenderProbability,
+		MinGenderCount:            minGenderCount,
+		MinNationalityProbability: minNationalityProbability,
+		MinNationalityCount:       minNationalityCount,
+	}
+}
+
+// loadProvider загружает настройки провайдера из переменных окружения <PREFIX>_URL, <PREFIX>_API_KEY,
+// <PREFIX>_TIMEOUT и <PREFIX>_ENABLED. По умолчанию провайдер включен, таймаут — 10 секунд.
+func loadProvider(prefix string) ProviderConfig {
+	timeout, err := time.ParseDuration(os.Getenv(prefix + "_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	enabled, err := strconv.ParseBool(os.Getenv(prefix + "_ENABLED"))
+	if err != nil {
+		enabled = true
+	}
+
+	return ProviderConfig{
+		BaseURL: os.Getenv(prefix + "_URL"),
+		APIKey:  os.Getenv(prefix + "_API_KEY"),
+		Timeout: timeout,
+		Enabled: enabled,
 	}
 }
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"effect/internal/service"
+)
+
+// AdminHandler отдает служебную информацию о состоянии сервиса.
+type AdminHandler struct {
+	Cache     *service.CachedEnricher
+	Composite *service.CompositeEnricher
+	Quotas    []*service.Quota
+}
+
+// NewAdminHandler создает обработчик служебных эндпоинтов.
+func NewAdminHandler(cache *service.CachedEnricher, composite *service.CompositeEnricher, quotas []*service.Quota) *AdminHandler {
+	return &AdminHandler{Cache: cache, Composite: composite, Quotas: quotas}
+}
+
+// CacheStats возвращает счетчики попаданий и промахов кэша обогащения.
+func (h *AdminHandler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	if h.Cache == nil {
+		http.Error(w, "enrichment cache disabled", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Cache.Stats())
+}
+
+// Breakers возвращает состояния автоматических выключателей провайдеров обогащения.
+func (h *AdminHandler) Breakers(w http.ResponseWriter, r *http.Request) {
+	statuses := []service.BreakerStatus{}
+	if h.Composite != nil {
+		statuses = h.Composite.BreakerStatuses()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// Quota возвращает оставшуюся квоту провайдеров обогащения.
+func (h *AdminHandler) Quota(w http.ResponseWriter, r *http.Request) {
+	statuses := make([]service.QuotaStatus, 0, len(h.Quotas))
+	for _, q := range h.Quotas {
+		statuses = append(statuses, q.Status())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// Metrics отдает квоты провайдеров в текстовом формате Prometheus.
+// Провайдеры, еще не сообщившие квоту, не выводятся.
+func (h *AdminHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	var known []service.QuotaStatus
+	for _, q := range h.Quotas {
+		if st := q.Status(); st.Known {
+			known = append(known, st)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	gauge := func(name, help string, value func(service.QuotaStatus) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, st := range known {
+			fmt.Fprintf(w, "%s{provider=%q} %g\n", name, st.Provider, value(st))
+		}
+	}
+	gauge("enrichment_quota_limit", "Provider quota per period (X-Rate-Limit-Limit).",
+		func(st service.QuotaStatus) float64 { return float64(st.Limit) })
+	gauge("enrichment_quota_remaining", "Remaining provider quota (X-Rate-Limit-Remaining).",
+		func(st service.QuotaStatus) float64 { return float64(st.Remaining) })
+	gauge("enrichment_quota_reset_seconds", "Seconds until the provider quota resets.",
+		func(st service.QuotaStatus) float64 { return math.Max(0, time.Until(*st.ResetAt).Seconds()) })
+	gauge("enrichment_quota_exhausted", "1 if calls to the provider are suspended until the quota resets.",
+		func(st service.QuotaStatus) float64 {
+			if st.Exhausted {
+				return 1
+			}
+			return 0
+		})
+}
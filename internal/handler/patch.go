@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+
+	"effect/internal/audit"
+	"effect/internal/model"
+	"effect/internal/patch"
+	"effect/internal/service"
+	"effect/internal/validation"
+)
+
+// Типы тела запроса PATCH.
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// acceptPatch — значение заголовка Accept-Patch (RFC 5789).
+const acceptPatch = mergePatchType + ", " + jsonPatchType
+
+// patchableFields — члены personInput, которые может затрагивать патч.
+var patchableFields = []string{
+	"name", "surname", "patronymic", "country_hint",
+	service.FieldAge, service.FieldGender, service.FieldNationality,
+}
+
+// Patch частично изменяет запись (PATCH /persons/{id}) по JSON Merge Patch (RFC 7396,
+// application/merge-patch+json или application/json) или JSON Patch (RFC 6902, application/json-patch+json).
+// Патч применяется к name, surname, patronymic, country_hint, age, gender и nationality.
+// Заданные патчем age, gender и nationality блокируются как ручные значения, а удаленные
+// (null в merge patch, remove в JSON Patch) разблокируются так же, как unlock в PUT.
+func (h *PersonHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "PersonHandler.Patch")
+	if !ok {
+		return
+	}
+	log.Infof("PersonHandler.Patch: patching person id=%d", id)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchType && mediaType != jsonPatchType && mediaType != "application/json" {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		http.Error(w, fmt.Sprintf("unsupported patch type %q", mediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.WithError(err).Warn("PersonHandler.Patch: failed to read body")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var apply func(doc interface{}) (interface{}, error)
+	var touched []string
+	if mediaType == jsonPatchType {
+		apply, touched, err = parseJSONPatch(body)
+	} else {
+		apply, touched, err = parseMergePatch(body)
+	}
+	if err != nil {
+		log.WithError(err).Warn("PersonHandler.Patch: invalid patch document")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var errs validation.Errors
+	for i, field := range touched {
+		if !slices.Contains(patchableFields, field) && !slices.Contains(touched[:i], field) {
+			errs.Add(field, validation.CodeReadOnly, "field %q cannot be patched", field)
+		}
+	}
+	if len(errs) > 0 {
+		log.WithError(errs).Warn("PersonHandler.Patch: invalid patch document")
+		writeRequestError(w, errs)
+		return
+	}
+
+	h.update(w, r, id, "PersonHandler.Patch", func(current model.Person) (personUpdate, error) {
+		return patchedUpdate(current, apply, touched)
+	})
+}
+
+// parseMergePatch разбирает merge patch и возвращает функцию его применения и затронутые члены.
+func parseMergePatch(body []byte) (func(interface{}) (interface{}, error), []string, error) {
+	var p map[string]interface{}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, nil, fmt.Errorf("merge patch must be a JSON object: %w", err)
+	}
+	touched := make([]string, 0, len(p))
+	for field := range p {
+		touched = append(touched, field)
+	}
+	return func(doc interface{}) (interface{}, error) { return patch.Merge(doc, p), nil }, touched, nil
+}
+
+// parseJSONPatch разбирает JSON Patch и возвращает функцию его применения и затронутые члены.
+// Операция над всем документом (path "") затрагивает все изменяемые поля.
+func parseJSONPatch(body []byte) (func(interface{}) (interface{}, error), []string, error) {
+	var ops []patch.Operation
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, nil, fmt.Errorf("JSON patch must be an array of operations: %w", err)
+	}
+
+	var touched []string
+	for _, op := range ops {
+		if err := op.Validate(); err != nil {
+			return nil, nil, err
+		}
+		if op.Op == "test" {
+			continue
+		}
+		paths := []string{op.Path}
+		if op.Op == "move" {
+			paths = append(paths, op.From)
+		}
+		for _, path := range paths {
+			field, _ := patch.RootMember(path)
+			if field == "" {
+				touched = append(touched, patchableFields...)
+				continue
+			}
+			touched = append(touched, field)
+		}
+	}
+	return func(doc interface{}) (interface{}, error) { return patch.Apply(doc, ops) }, touched, nil
+}
+
+// patchedUpdate применяет патч к изменяемым полям current (все они присутствуют в документе,
+// незаполненные — как null) и строит из результата запрос на обновление.
+func patchedUpdate(current model.Person, apply func(interface{}) (interface{}, error), touched []string) (personUpdate, error) {
+	var doc map[string]interface{}
+	raw, _ := json.Marshal(inputOf(current))
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return personUpdate{}, err
+	}
+	// незаполненные поля присутствуют в документе как null, чтобы к ним применялись replace и test
+	for _, field := range patchableFields {
+		if _, ok := doc[field]; !ok {
+			doc[field] = nil
+		}
+	}
+
+	patched, err := apply(doc)
+	switch {
+	case errors.Is(err, patch.ErrTestFailed):
+		return personUpdate{}, &statusError{status: http.StatusConflict, err: err}
+	case err != nil:
+		return personUpdate{}, &statusError{status: http.StatusUnprocessableEntity, err: err}
+	}
+
+	raw, err = json.Marshal(patched)
+	if err != nil {
+		return personUpdate{}, err
+	}
+	var result personInput
+	if err := validation.Decode(bytes.NewReader(raw), &result); err != nil {
+		return personUpdate{}, err
+	}
+
+	req := personUpdate{Person: result.toPerson()}
+	// ручными становятся только затронутые патчем атрибуты; удаленные разблокируются
+	attrs := audit.FieldValues(model.Person{Age: result.Age, Gender: result.Gender, Nationality: result.Nationality})
+	for field, value := range attrs {
+		if !slices.Contains(touched, field) {
+			continue
+		}
+		if value == nil {
+			req.Unlock = append(req.Unlock, field)
+			continue
+		}
+		if err := audit.SetFieldValue(&req.Person, field, value); err != nil {
+			return personUpdate{}, err
+		}
+	}
+
+	if errs := validateUpdate(&req); len(errs) > 0 {
+		return personUpdate{}, errs
+	}
+	return req, nil
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"effect/internal/model"
+	"effect/internal/service"
+)
+
+// patchRequest возвращает запрос PATCH /persons/1 с телом body типа contentType.
+func patchRequest(contentType, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPatch, "/persons/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+// TestPatch_MergePatch проверяет, что merge patch меняет только переданные поля, а смена имени обогащает
+// запись заново: новое значение атрибута блокируется, null снимает блокировку, остальные поля сохраняются.
+func TestPatch_MergePatch(t *testing.T) {
+	age, gender, inferred := 50, "male", 33
+	repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov", Age: &age, Gender: &gender, LockedFields: []string{"age", "gender"}})
+	h := NewPersonHandler(repo, &stubEnricher{res: &service.EnrichResult{Age: &inferred}})
+
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, patchRequest(mergePatchType, `{"name":"Anna","gender":null,"nationality":"ru"}`))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rw.Code, rw.Body)
+	}
+	if got := rw.Header().Get("X-Enrichment"); got != "refreshed" {
+		t.Errorf("expected X-Enrichment refreshed, got %q", got)
+	}
+
+	p := mustGet(t, repo, 1)
+	if p.Name != "Anna" || p.Surname != "Ivanov" || *p.Age != 50 || p.Gender != nil || p.Nationality == nil || *p.Nationality != "RU" {
+		t.Errorf("unexpected stored person %+v", p)
+	}
+	if strings.Join(p.LockedFields, ",") != "age,nationality" {
+		t.Errorf("expected age and nationality locked, got %v", p.LockedFields)
+	}
+}
+
+// TestPatch_JSONPatch проверяет применение JSON Patch и то, что атрибуты без изменения имени не переобогащаются.
+func TestPatch_JSONPatch(t *testing.T) {
+	repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov"})
+	h := NewPersonHandler(repo, &stubEnricher{err: errStorage})
+
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, patchRequest(jsonPatchType,
+		`[{"op":"test","path":"/name","value":"Ivan"},{"op":"add","path":"/age","value":30},{"op":"add","path":"/patronymic","value":"Petrovich"}]`))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rw.Code, rw.Body)
+	}
+
+	p := mustGet(t, repo, 1)
+	if p.Age == nil || *p.Age != 30 || p.Patronymic == nil || *p.Patronymic != "Petrovich" {
+		t.Errorf("unexpected stored person %+v", p)
+	}
+	if strings.Join(p.LockedFields, ",") != "age" {
+		t.Errorf("expected age locked, got %v", p.LockedFields)
+	}
+}
+
+// TestPatch_NullFields проверяет, что незаполненные поля есть в документе патча как null:
+// test и replace по ним применяются так же, как по заполненным.
+func TestPatch_NullFields(t *testing.T) {
+	repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov"})
+	h := NewPersonHandler(repo, &stubEnricher{err: errStorage})
+
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, patchRequest(jsonPatchType,
+		`[{"op":"test","path":"/patronymic","value":null},{"op":"replace","path":"/age","value":30}]`))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rw.Code, rw.Body)
+	}
+	if p := mustGet(t, repo, 1); p.Age == nil || *p.Age != 30 || strings.Join(p.LockedFields, ",") != "age" {
+		t.Errorf("expected age 30 set and locked, got %+v", p)
+	}
+
+	rw = httptest.NewRecorder()
+	route(h).ServeHTTP(rw, patchRequest(jsonPatchType, `[{"op":"test","path":"/gender","value":"male"}]`))
+	if rw.Code != http.StatusConflict {
+		t.Errorf("expected 409 for failed test on null field, got %d: %s", rw.Code, rw.Body)
+	}
+}
+
+// TestPatch_Errors проверяет статусы ответов на неподходящие патчи; запись при этом не меняется.
+func TestPatch_Errors(t *testing.T) {
+	cases := []struct {
+		name, contentType, body string
+		want                    int
+	}{
+		{"unsupported type", "text/plain", `{"surname":"Petrov"}`, http.StatusUnsupportedMediaType},
+		{"malformed merge patch", mergePatchType, `[1]`, http.StatusBadRequest},
+		{"unknown operation", jsonPatchType, `[{"op":"frobnicate","path":"/name"}]`, http.StatusBadRequest},
+		{"immutable field", mergePatchType, `{"id":7}`, http.StatusUnprocessableEntity},
+		{"immutable field via from", jsonPatchType, `[{"op":"copy","from":"/locked_fields","path":"/name"}]`, http.StatusUnprocessableEntity},
+		{"missing member", jsonPatchType, `[{"op":"replace","path":"/age/value","value":30}]`, http.StatusUnprocessableEntity},
+		{"wrong type", mergePatchType, `{"age":"old"}`, http.StatusUnprocessableEntity},
+		{"test failed", jsonPatchType, `[{"op":"test","path":"/name","value":"Anna"},{"op":"replace","path":"/name","value":"Anna"}]`, http.StatusConflict},
+		{"empty surname", mergePatchType, `{"surname":null}`, http.StatusUnprocessableEntity},
+		{"invalid override", mergePatchType, `{"gender":"robot"}`, http.StatusUnprocessableEntity},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov"})
+			h := NewPersonHandler(repo, &stubEnricher{res: &service.EnrichResult{}})
+			rw := httptest.NewRecorder()
+			route(h).ServeHTTP(rw, patchRequest(c.contentType, c.body))
+			if rw.Code != c.want {
+				t.Fatalf("expected %d, got %d: %s", c.want, rw.Code, rw.Body)
+			}
+			if c.want == http.StatusUnsupportedMediaType && rw.Header().Get("Accept-Patch") != acceptPatch {
+				t.Errorf("expected Accept-Patch header, got %q", rw.Header().Get("Accept-Patch"))
+			}
+			if p := mustGet(t, repo, 1); p.Name != "Ivan" || p.Surname != "Ivanov" {
+				t.Errorf("person must not change, got %+v", p)
+			}
+		})
+	}
+}
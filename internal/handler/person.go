@@ -1,133 +1,115 @@
 package handler
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"effect/internal/audit"
 	"effect/internal/model"
+	"effect/internal/repository"
 	"effect/internal/service"
+	"effect/internal/validation"
 )
 
 type PersonHandler struct {
-	DB *sql.DB
+	Repo     repository.PersonRepository
+	Enricher service.Enricher
+	// Async включает асинхронное обогащение: Create сохраняет запись со статусом pending
+	// и отвечает 202, а обогащение выполняют воркеры из пакета queue.
+	Async bool
+	// QueueOnError включает постановку в очередь повторного обогащения, если при смене имени
+	// синхронное обогащение не удалось: изменение сохраняется, а не отклоняется с 503.
+	// Включается, когда работают воркеры очереди.
+	QueueOnError bool
+}
+
+// NewPersonHandler создает обработчик с указанным хранилищем записей и источником обогащения.
+func NewPersonHandler(repo repository.PersonRepository, enricher service.Enricher) *PersonHandler {
+	return &PersonHandler{Repo: repo, Enricher: enricher}
 }
 
 func (h *PersonHandler) Create(w http.ResponseWriter, r *http.Request) {
 	log.Debug("PersonHandler.Create: decoding request body")
-	var p model.Person
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+	// атрибуты при создании заполняет обогащение, а блокировки ставят только ручные значения в PUT и PATCH,
+	// поэтому тело содержит только имя и подсказку страны
+	var in personFields
+	if err := validation.Decode(r.Body, &in); err != nil {
 		log.WithError(err).Warn("PersonHandler.Create: invalid request payload")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
 		return
 	}
-
-	log.Infof("PersonHandler.Create: enriching data for name=%s", p.Name)
-	info, err := service.Enrich(p.Name)
-	if err != nil {
-		log.WithError(err).Error("PersonHandler.Create: enrich error")
-		http.Error(w, "enrich error: "+err.Error(), http.StatusInternalServerError)
+	p := in.toPerson()
+	if errs := append(validation.Person(p), normalizeCountryHint(&p)...); len(errs) > 0 {
+		log.WithError(errs).Warn("PersonHandler.Create: invalid person")
+		writeRequestError(w, errs)
 		return
 	}
-	p.Age, p.Gender, p.Nationality = info.Age, info.Gender, info.Nationality
 
-	p.Message = fmt.Sprintf(
-		"%s %s%s: age %v, gender %v, nationality %v",
-		p.Name, p.Surname,
-		func() string {
-			if p.Patronymic != nil {
-				return " " + *p.Patronymic
-			}
-			return ""
-		}(),
-		ptrToString(p.Age, "unknown"),
-		ptrToString(p.Gender, "unknown"),
-		ptrToString(p.Nationality, "unknown"),
-	)
+	status := http.StatusCreated
+	var provenance model.Provenance
+	if h.Async {
+		log.Infof("PersonHandler.Create: queueing enrichment for name=%s", p.Name)
+		p.EnrichmentStatus = model.EnrichmentPending
+		status = http.StatusAccepted
+	} else {
+		log.Infof("PersonHandler.Create: enriching data for name=%s", p.Name)
+		info, err := h.Enricher.Enrich(r.Context(), enrichQuery(p))
+		if err != nil && r.Context().Err() != nil {
+			// клиент закрыл соединение: отвечать некому, запись не создается
+			log.WithError(err).Warnf("PersonHandler.Create: request cancelled during enrichment for name=%s", p.Name)
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("PersonHandler.Create: enrich error")
+			writeEnrichError(w, err)
+			return
+		}
+		provenance = applyEnrichment(&p, info, nil)
+	}
 
-	query := `
-		INSERT INTO persons (name, surname, patronymic, age, gender, nationality)
-		VALUES ($1,$2,$3,$4,$5,$6) RETURNING id, created_at`
-	log.Debug("PersonHandler.Create: executing DB insert")
-	if err := h.DB.QueryRow(query,
-		p.Name, p.Surname, p.Patronymic, p.Age, p.Gender, p.Nationality,
-	).Scan(&p.ID, &p.CreatedAt); err != nil {
+	log.Debug("PersonHandler.Create: saving person")
+	if err := h.Repo.Create(r.Context(), &p, provenance); err != nil {
 		log.WithError(err).Error("PersonHandler.Create: failed to insert person")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	p.Message = describe(p)
 
 	log.Infof("PersonHandler.Create: created person ID=%d", p.ID)
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(p)
 }
 
 func (h *PersonHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	log.Debug("PersonHandler.GetAll: parsing query params")
 	q := r.URL.Query()
-	var where []string
-	var args []interface{}
-	idx := 1
-	if v := q.Get("name"); v != "" {
-		where = append(where, fmt.Sprintf("name ILIKE $%d", idx))
-		args = append(args, "%"+v+"%")
-		idx++
-	}
-	if v := q.Get("surname"); v != "" {
-		where = append(where, fmt.Sprintf("surname ILIKE $%d", idx))
-		args = append(args, "%"+v+"%")
-		idx++
+	f := repository.ListFilter{Name: q.Get("name"), Surname: q.Get("surname")}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if f.Limit <= 0 {
+		f.Limit = 20
 	}
+	f.Offset, _ = strconv.Atoi(q.Get("offset"))
 
-	base := `
-		SELECT id, name, surname, patronymic, age, gender, nationality, created_at
-		FROM persons`
-	if len(where) > 0 {
-		base += " WHERE " + strings.Join(where, " AND ")
-	}
-
-	limit, _ := strconv.Atoi(q.Get("limit"))
-	if limit <= 0 {
-		limit = 20
-	}
-
-	offset, _ := strconv.Atoi(q.Get("offset"))
-	base += fmt.Sprintf(" ORDER BY id LIMIT %d OFFSET %d", limit, offset)
-
-	log.Debugf("PersonHandler.GetAll: executing query: %s args=%v", base, args)
-	rows, err := h.DB.Query(base, args...)
+	log.Debugf("PersonHandler.GetAll: listing persons %+v", f)
+	result, err := h.Repo.List(r.Context(), f)
 	if err != nil {
 		log.WithError(err).Error("PersonHandler.GetAll: query failed")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var result []model.Person
-	for rows.Next() {
-		var p model.Person
-		rows.Scan(&p.ID, &p.Name, &p.Surname, &p.Patronymic,
-			&p.Age, &p.Gender, &p.Nationality, &p.CreatedAt)
-
-		fullName := p.Name + " " + p.Surname
-		if p.Patronymic != nil {
-			fullName += " " + *p.Patronymic
-		}
-
-		p.Message = fmt.Sprintf(
-			"%s: age %s, gender %s, nationality %s",
-			fullName,
-			ptrToString(p.Age, "unknown"),
-			ptrToString(p.Gender, "unknown"),
-			ptrToString(p.Nationality, "unknown"),
-		)
-
-		result = append(result, p)
+	for i := range result {
+		result[i].Message = describe(result[i])
 	}
 
 	log.Infof("PersonHandler.GetAll: returning %d persons", len(result))
@@ -136,108 +118,558 @@ func (h *PersonHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *PersonHandler) GetByID(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/persons/")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		log.WithError(err).Warnf("PersonHandler.GetByID: invalid id %s", idStr)
-		http.Error(w, "invalid id", http.StatusBadRequest)
+	id, ok := pathID(w, r, "PersonHandler.GetByID")
+	if !ok {
 		return
 	}
 	log.Infof("PersonHandler.GetByID: fetching person id=%d", id)
 
-	if h.DB == nil {
+	p, err := h.Repo.Get(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
+	} else if err != nil {
+		log.WithError(err).Error("PersonHandler.GetByID: query failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	p.Message = describe(p)
 
-	var p model.Person
-	err = h.DB.QueryRow(
-		`SELECT id, name, surname, patronymic, age, gender, nationality, created_at FROM persons WHERE id=$1`,
-		id,
-	).Scan(&p.ID, &p.Name, &p.Surname, &p.Patronymic,
-		&p.Age, &p.Gender, &p.Nationality, &p.CreatedAt)
-	if err == sql.ErrNoRows {
+	if hasExpand(r, "provenance") {
+		if p.Provenance, err = h.Repo.Provenance(r.Context(), id); err != nil {
+			log.WithError(err).Error("PersonHandler.GetByID: provenance query failed")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// EnrichmentStatus возвращает состояние асинхронного обогащения записи (GET /persons/{id}/enrichment).
+func (h *PersonHandler) EnrichmentStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "PersonHandler.EnrichmentStatus")
+	if !ok {
+		return
+	}
+
+	st, err := h.Repo.EnrichmentState(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	} else if err != nil {
-		log.WithError(err).Error("PersonHandler.GetByID: query failed")
+		log.WithError(err).Error("PersonHandler.EnrichmentStatus: query failed")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	fullName := p.Name + " " + p.Surname
-	if p.Patronymic != nil {
-		fullName += " " + *p.Patronymic
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// History возвращает историю изменений атрибутов записи (GET /persons/{id}/history).
+func (h *PersonHandler) History(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "PersonHandler.History")
+	if !ok {
+		return
 	}
 
-	p.Message = fmt.Sprintf(
-		"%s: age %s, gender %s, nationality %s",
-		fullName,
-		ptrToString(p.Age, "unknown"),
-		ptrToString(p.Gender, "unknown"),
-		ptrToString(p.Nationality, "unknown"),
-	)
+	_, err := h.Repo.Get(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.WithError(err).Error("PersonHandler.History: query failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	history, err := h.Repo.History(r.Context(), id)
+	if err != nil {
+		log.WithError(err).Error("PersonHandler.History: history query failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(p)
+	json.NewEncoder(w).Encode(history)
+}
+
+// personFields — поля записи, которые задает клиент; тело запроса POST /persons.
+type personFields struct {
+	Name        string  `json:"name"`
+	Surname     string  `json:"surname"`
+	Patronymic  *string `json:"patronymic,omitempty"`
+	CountryHint *string `json:"country_hint,omitempty"`
+}
+
+func (f personFields) toPerson() model.Person {
+	return model.Person{Name: f.Name, Surname: f.Surname, Patronymic: f.Patronymic, CountryHint: f.CountryHint}
+}
+
+// personInput — изменяемые поля записи: поля клиента и ручные значения атрибутов.
+// Из них состоит тело PUT /persons/{id}, к их JSON-представлению применяется PATCH.
+type personInput struct {
+	personFields
+	Age         *int    `json:"age,omitempty"`
+	Gender      *string `json:"gender,omitempty"`
+	Nationality *string `json:"nationality,omitempty"`
+}
+
+// inputOf возвращает изменяемые поля записи p.
+func inputOf(p model.Person) personInput {
+	return personInput{
+		personFields: personFields{Name: p.Name, Surname: p.Surname, Patronymic: p.Patronymic, CountryHint: p.CountryHint},
+		Age:          p.Age, Gender: p.Gender, Nationality: p.Nationality,
+	}
+}
+
+// personUpdate — изменение записи из PUT или PATCH.
+// Переданные age, gender и nationality, отличающиеся от сохраненных, становятся ручными значениями
+// и блокируются от перезаписи при повторном обогащении.
+type personUpdate struct {
+	model.Person
+	// Unlock — атрибуты, с которых снимается блокировка; им возвращается последнее предсказанное значение.
+	Unlock []string `json:"unlock,omitempty"`
 }
 
 func (h *PersonHandler) Update(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/persons/")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		log.WithError(err).Warnf("PersonHandler.Update: invalid id %s", idStr)
-		http.Error(w, "invalid id", http.StatusBadRequest)
+	id, ok := pathID(w, r, "PersonHandler.Update")
+	if !ok {
 		return
 	}
 	log.Infof("PersonHandler.Update: updating person id=%d", id)
 
-	var p model.Person
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+	var body struct {
+		personInput
+		Unlock []string `json:"unlock,omitempty"`
+	}
+	if err := validation.Decode(r.Body, &body); err != nil {
 		log.WithError(err).Warn("PersonHandler.Update: invalid request payload")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
+		return
+	}
+	req := personUpdate{Person: body.toPerson(), Unlock: body.Unlock}
+	req.Age, req.Gender, req.Nationality = body.Age, body.Gender, body.Nationality
+	if errs := validateUpdate(&req); len(errs) > 0 {
+		log.WithError(errs).Warn("PersonHandler.Update: invalid person")
+		writeRequestError(w, errs)
 		return
 	}
 
-	res, err := h.DB.Exec(
-		`UPDATE persons SET name=$1, surname=$2, patronymic=$3 WHERE id=$4`,
-		p.Name, p.Surname, p.Patronymic, id,
-	)
+	h.update(w, r, id, "PersonHandler.Update", func(model.Person) (personUpdate, error) {
+		return req, nil
+	})
+}
+
+// update применяет к записи id изменение, которое build строит по ее текущему состоянию.
+// Если имя изменилось, атрибуты обогащаются заново (или обогащение ставится в очередь), измененные
+// значения атрибутов блокируются как ручные. Ошибка build отдается с ее статусом (см. statusError), ошибки полей
+// (validation.Errors) — как 422, остальные — как 400.
+//
+// Внешние API вызываются до транзакции, чтобы не держать блокировку записи на время обогащения;
+// под блокировкой build применяется к актуальному состоянию, и результат обогащения используется, только
+// если он получен для того же имени. Иначе, а также при ошибке обогащения, если включен QueueOnError,
+// обогащение ставится в очередь.
+func (h *PersonHandler) update(w http.ResponseWriter, r *http.Request, id int, caller string,
+	build func(current model.Person) (personUpdate, error)) {
+	ctx := r.Context()
+	current, err := h.Repo.Get(ctx, id)
 	if err != nil {
-		log.WithError(err).Error("PersonHandler.Update: exec failed")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeUpdateError(w, caller, id, err)
 		return
 	}
-	if n, _ := res.RowsAffected(); n == 0 {
-		http.Error(w, "not found", http.StatusNotFound)
+	req, err := build(current)
+	if err != nil {
+		writeBuildError(w, caller, id, err)
+		return
+	}
+
+	var info *service.EnrichResult
+	query := enrichQuery(req.Person)
+	if nameChanged(current, req) && !h.Async {
+		log.Infof("%s: name changed, re-enriching id=%d", caller, id)
+		info, err = h.Enricher.Enrich(ctx, query)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			log.WithError(err).Warnf("%s: request cancelled during enrichment for id=%d", caller, id)
+			return
+		case err != nil && !h.QueueOnError:
+			log.WithError(err).Errorf("%s: enrich error", caller)
+			writeEnrichError(w, err)
+			return
+		case err != nil:
+			log.WithError(err).Warnf("%s: enrich error, queueing re-enrichment for id=%d", caller, id)
+		}
+	}
+
+	// buildErr отделяет ошибки запроса от ошибок хранилища: у них разные коды ответа
+	var buildErr error
+	enrichment := ""
+	err = h.Repo.Update(ctx, id, func(p *model.Person, history repository.History) (repository.Change, error) {
+		change := repository.Change{Provenance: model.Provenance{}}
+		req, err := build(*p)
+		if err != nil {
+			buildErr = err
+			return change, err
+		}
+		if err := dropUnchanged(*p, &req); err != nil {
+			return change, fmt.Errorf("compare overrides: %w", err)
+		}
+
+		changed := nameChanged(*p, req)
+		p.Name, p.Surname, p.Patronymic, p.CountryHint = req.Name, req.Surname, req.Patronymic, req.CountryHint
+
+		// прежние age, gender и nationality относятся к старому имени: обогащаем заново,
+		// не трогая атрибуты, которые останутся заблокированными после этого запроса
+		switch {
+		case !changed:
+		case info != nil && enrichQuery(*p) == query:
+			change.Provenance = applyEnrichment(p, info, lockedAfter(p.LockedFields, req))
+			enrichment = "refreshed"
+		case h.Async || h.QueueOnError:
+			log.Infof("%s: name changed, queueing re-enrichment for id=%d", caller, id)
+			p.EnrichmentStatus = model.EnrichmentPending
+			change.Requeue = true
+			enrichment = "queued"
+		default:
+			// имя изменилось одновременно с запросом, а очереди нет: результат обогащения не подходит
+			buildErr = &statusError{status: http.StatusConflict, err: errors.New("person was modified concurrently, retry the request")}
+			return change, buildErr
+		}
+
+		if err := applyOverrides(ctx, history, p, req, change.Provenance); err != nil {
+			return change, fmt.Errorf("apply overrides: %w", err)
+		}
+		return change, nil
+	})
+	switch {
+	case buildErr != nil:
+		writeBuildError(w, caller, id, buildErr)
+		return
+	case err != nil:
+		writeUpdateError(w, caller, id, err)
 		return
 	}
 
+	if enrichment != "" {
+		w.Header().Set("X-Enrichment", enrichment)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// nameChanged сообщает, меняет ли req имя записи p, к которому относятся обогащенные атрибуты.
+func nameChanged(p model.Person, req personUpdate) bool {
+	return !strings.EqualFold(strings.TrimSpace(p.Name), strings.TrimSpace(req.Name))
+}
+
+// writeBuildError отвечает на ошибку построения изменения: statusError — с ее статусом, остальные — как writeRequestError.
+func writeBuildError(w http.ResponseWriter, caller string, id int, err error) {
+	var se *statusError
+	if errors.As(err, &se) {
+		log.WithError(err).Warnf("%s: cannot apply request to id=%d", caller, id)
+		http.Error(w, se.Error(), se.status)
+		return
+	}
+	log.WithError(err).Warnf("%s: invalid request for id=%d", caller, id)
+	writeRequestError(w, err)
+}
+
+// writeUpdateError отвечает на ошибку хранилища при обновлении записи id.
+func writeUpdateError(w http.ResponseWriter, caller string, id int, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	log.WithError(err).Errorf("%s: update of id=%d failed", caller, id)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// statusError — ошибка запроса с HTTP-статусом ответа.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+
+func (e *statusError) Unwrap() error { return e.err }
+
+// validateUpdate проверяет запрос на обновление: имя, фамилию и отчество (см. validation.Person),
+// country_hint и ручные значения атрибутов.
+func validateUpdate(req *personUpdate) validation.Errors {
+	errs := validation.Person(req.Person)
+	errs = append(errs, normalizeCountryHint(&req.Person)...)
+	return append(errs, validateOverrides(req)...)
+}
+
+// validateOverrides проверяет ручные значения атрибутов и список снимаемых блокировок.
+func validateOverrides(req *personUpdate) validation.Errors {
+	var errs validation.Errors
+	if req.Age != nil && (*req.Age < 0 || *req.Age > 150) {
+		errs.Add(service.FieldAge, validation.CodeOutOfRange, "invalid age %d: expected 0..150", *req.Age)
+	}
+	if req.Gender != nil && *req.Gender != "male" && *req.Gender != "female" {
+		errs.Add(service.FieldGender, validation.CodeInvalidValue, "invalid gender %q: expected male or female", *req.Gender)
+	}
+	if req.Nationality != nil {
+		nationality := strings.ToUpper(strings.TrimSpace(*req.Nationality))
+		if isCountryCode(nationality) {
+			req.Nationality = &nationality
+		} else {
+			errs.Add(service.FieldNationality, validation.CodeInvalidValue, "invalid nationality %q: expected ISO 3166-1 alpha-2 code", *req.Nationality)
+		}
+	}
+
+	set := audit.FieldValues(req.Person)
+	for _, field := range req.Unlock {
+		value, ok := set[field]
+		if !ok {
+			errs.Add("unlock", validation.CodeInvalidValue, "cannot unlock unknown field %q", field)
+		} else if value != nil {
+			errs.Add("unlock", validation.CodeInvalidValue, "field %q cannot be both set and unlocked", field)
+		}
+	}
+	return errs
+}
+
+// dropUnchanged убирает из req значения атрибутов, совпадающие с сохраненными в p. Такие значения
+// клиент возвращает без изменений после GET, и блокировка их как ручных остановила бы повторное обогащение.
+func dropUnchanged(p model.Person, req *personUpdate) error {
+	stored := audit.FieldValues(p)
+	for field, value := range audit.FieldValues(req.Person) {
+		if value == nil || stored[field] == nil || *value != *stored[field] {
+			continue
+		}
+		if err := audit.SetFieldValue(&req.Person, field, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyOverrides переносит в p ручные значения из req и блокирует их, а для снимаемых блокировок
+// восстанавливает последнее предсказанное значение, если атрибут не был только что обогащен заново.
+// Происхождение измененных атрибутов добавляется в provenance.
+func applyOverrides(ctx context.Context, history repository.History, p *model.Person, req personUpdate, provenance model.Provenance) error {
+	locked := make(map[string]bool, len(p.LockedFields))
+	for _, field := range p.LockedFields {
+		locked[field] = true
+	}
+
+	manual := model.FieldProvenance{Source: model.SourceManual, EnrichedAt: time.Now().UTC()}
+	for field, value := range audit.FieldValues(req.Person) {
+		if value == nil {
+			continue
+		}
+		if err := audit.SetFieldValue(p, field, value); err != nil {
+			return err
+		}
+		locked[field] = true
+		provenance[field] = manual
+	}
+
+	for _, field := range req.Unlock {
+		if !locked[field] {
+			continue
+		}
+		delete(locked, field)
+		if _, fresh := provenance[field]; fresh {
+			continue
+		}
+
+		fp, found, err := history.LatestInferred(ctx, p.ID, field)
+		if err != nil {
+			return err
+		}
+		if !found {
+			// предсказаний не было: значение сбрасывается до следующего обогащения
+			if err := audit.SetFieldValue(p, field, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := audit.SetFieldValue(p, field, fp.Value); err != nil {
+			return err
+		}
+		provenance[field] = fp
+	}
+
+	p.LockedFields = p.LockedFields[:0]
+	for field := range locked {
+		p.LockedFields = append(p.LockedFields, field)
+	}
+	sort.Strings(p.LockedFields)
+	return nil
+}
+
+// lockedAfter возвращает атрибуты, которые останутся заблокированными после применения req:
+// текущие блокировки без снимаемых плюс заданные вручную в req.
+func lockedAfter(locked []string, req personUpdate) []string {
+	var out []string
+	for _, field := range locked {
+		if !slices.Contains(req.Unlock, field) {
+			out = append(out, field)
+		}
+	}
+	for field, value := range audit.FieldValues(req.Person) {
+		if value != nil && !slices.Contains(out, field) {
+			out = append(out, field)
+		}
+	}
+	return out
+}
+
+// applyEnrichment переносит результат обогащения в p, не трогая атрибуты из locked и их выборки,
+// и возвращает происхождение перенесенных атрибутов.
+func applyEnrichment(p *model.Person, info *service.EnrichResult, locked []string) model.Provenance {
+	if !slices.Contains(locked, service.FieldAge) {
+		p.Age, p.AgeSampleCount = info.Age, info.AgeCount
+	}
+	if !slices.Contains(locked, service.FieldGender) {
+		p.Gender, p.GenderProbability, p.GenderSampleCount = info.Gender, info.GenderProbability, info.GenderCount
+	}
+	if !slices.Contains(locked, service.FieldNationality) {
+		p.Nationality, p.NationalityCandidates, p.NationalitySampleCount = info.Nationality, info.NationalityCandidates, info.NationalityCount
+	}
+	p.MissingFields = info.Missing
+	p.LowConfidenceFields = info.LowConfidence
+	p.EnrichmentSource, p.FallbackFields = stringOrNil(info.Source), info.FallbackFields
+	p.EnrichmentStatus = model.EnrichmentDone
+	now := time.Now().UTC()
+	p.EnrichedAt = &now
+	return audit.Unlocked(info.Provenance, locked)
+}
+
 func (h *PersonHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/persons/")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		log.WithError(err).Warnf("PersonHandler.Delete: invalid id %s", idStr)
-		http.Error(w, "invalid id", http.StatusBadRequest)
+	id, ok := pathID(w, r, "PersonHandler.Delete")
+	if !ok {
 		return
 	}
 	log.Infof("PersonHandler.Delete: deleting person id=%d", id)
 
-	res, err := h.DB.Exec("DELETE FROM persons WHERE id=$1", id)
-	if err != nil {
+	err := h.Repo.Delete(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	} else if err != nil {
 		log.WithError(err).Error("PersonHandler.Delete: exec failed")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if n, _ := res.RowsAffected(); n == 0 {
-		http.Error(w, "not found", http.StatusNotFound)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hasExpand сообщает, запрошено ли расширенное представление name в параметре expand
+// (например, ?expand=provenance или ?expand=provenance,history).
+func hasExpand(r *http.Request, name string) bool {
+	for _, v := range r.URL.Query()["expand"] {
+		for _, part := range strings.Split(v, ",") {
+			if strings.TrimSpace(part) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enrichQuery строит запрос на обогащение по данным записи.
+func enrichQuery(p model.Person) service.Query {
+	q := service.Query{Name: p.Name}
+	if p.CountryHint != nil {
+		q.CountryID = *p.CountryHint
+	}
+	return q
+}
+
+// normalizeCountryHint проверяет, что country_hint — двухбуквенный код страны, и приводит его к верхнему регистру.
+// Пустая строка трактуется как отсутствие подсказки.
+func normalizeCountryHint(p *model.Person) validation.Errors {
+	if p.CountryHint == nil {
+		return nil
+	}
+	hint := strings.ToUpper(strings.TrimSpace(*p.CountryHint))
+	if hint == "" {
+		p.CountryHint = nil
+		return nil
+	}
+	if !isCountryCode(hint) {
+		var errs validation.Errors
+		errs.Add("country_hint", validation.CodeInvalidValue, "invalid country_hint %q: expected ISO 3166-1 alpha-2 code", *p.CountryHint)
+		return errs
+	}
+	p.CountryHint = &hint
+	return nil
+}
+
+// isCountryCode сообщает, является ли s двухбуквенным кодом страны в верхнем регистре.
+func isCountryCode(s string) bool {
+	return len(s) == 2 && s[0] >= 'A' && s[0] <= 'Z' && s[1] >= 'A' && s[1] <= 'Z'
+}
+
+// writeRequestError отвечает на некорректный запрос: ошибки полей (validation.Errors) отдаются
+// как 422 со списком {"errors": [...]}, остальные ошибки — как 400.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(struct {
+		Errors validation.Errors `json:"errors"`
+	}{errs})
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// writeEnrichError отвечает ошибкой обогащения; при исчерпанной квоте провайдера
+// в заголовке Retry-After сообщается, через сколько секунд она восстановится.
+func writeEnrichError(w http.ResponseWriter, err error) {
+	if resetAt, ok := service.QuotaResetAt(err); ok {
+		secs := max(1, int(math.Ceil(time.Until(resetAt).Seconds())))
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+	}
+	http.Error(w, "enrich error: "+err.Error(), enrichErrorStatus(err))
+}
+
+// enrichErrorStatus возвращает HTTP-статус для ошибки обогащения.
+// Недоступность или ограничение частоты запросов внешних API отдается как 503,
+// чтобы клиент мог повторить запрос позже; превышение срока обогащения — как 504.
+func enrichErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if errors.Is(err, service.ErrRateLimited) ||
+		errors.Is(err, service.ErrUpstreamUnavailable) ||
+		errors.Is(err, service.ErrCircuitOpen) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// describe формирует текстовое описание записи для поля message.
+func describe(p model.Person) string {
+	fullName := p.Name + " " + p.Surname
+	if p.Patronymic != nil {
+		fullName += " " + *p.Patronymic
+	}
+	return fmt.Sprintf(
+		"%s: age %s, gender %s, nationality %s",
+		fullName,
+		ptrToString(p.Age, "unknown"),
+		ptrToString(p.Gender, "unknown"),
+		ptrToString(p.Nationality, "unknown"),
+	)
+}
+
+// stringOrNil возвращает nil для пустой строки.
+func stringOrNil(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
 
 // ptrToString преобразует указатель на значение типа T в строку.
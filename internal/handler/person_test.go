@@ -2,30 +2,98 @@ package handler
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"effect/internal/model"
+	"effect/internal/repository"
+	"effect/internal/service"
+	"effect/internal/validation"
 )
 
-// Заглушка для sql.DB
-type fakeDB struct{}
+// seedRepo создает хранилище в памяти с записями persons; ID назначаются по порядку с 1.
+func seedRepo(t *testing.T, persons ...model.Person) *repository.MemoryRepository {
+	t.Helper()
+	repo := repository.NewMemoryRepository()
+	for i := range persons {
+		if err := repo.Create(context.Background(), &persons[i], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+// failingRepo — хранилище, каждый метод которого возвращает ошибку.
+type failingRepo struct{}
+
+var errStorage = errors.New("storage down")
+
+func (failingRepo) Create(context.Context, *model.Person, model.Provenance) error { return errStorage }
+func (failingRepo) Get(context.Context, int) (model.Person, error)                { return model.Person{}, errStorage }
+func (failingRepo) List(context.Context, repository.ListFilter) ([]model.Person, error) {
+	return nil, errStorage
+}
+func (failingRepo) Update(context.Context, int, repository.UpdateFunc) error { return errStorage }
+func (failingRepo) Delete(context.Context, int) error                        { return errStorage }
+func (failingRepo) Provenance(context.Context, int) (model.Provenance, error) {
+	return nil, errStorage
+}
+func (failingRepo) History(context.Context, int) ([]model.HistoryEntry, error) {
+	return nil, errStorage
+}
+func (failingRepo) EnrichmentState(context.Context, int) (model.EnrichmentState, error) {
+	return model.EnrichmentState{}, errStorage
+}
+
+// route возвращает маршрутизатор с маршрутами h.
+func route(h *PersonHandler) *http.ServeMux {
+	mux := http.NewServeMux()
+	h.Register(mux)
+	return mux
+}
+
+// mustGet возвращает запись id из repo.
+func mustGet(t *testing.T, repo repository.PersonRepository, id int) model.Person {
+	t.Helper()
+	p, err := repo.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
 
-func (f *fakeDB) QueryRow(query string, args ...interface{}) *sql.Row {
-	// Возвращаем sql.ErrNoRows
-	return &sql.Row{}
+// mustProvenance возвращает происхождение атрибутов записи id из repo.
+func mustProvenance(t *testing.T, repo repository.PersonRepository, id int) model.Provenance {
+	t.Helper()
+	prov, err := repo.Provenance(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prov
 }
-func (f *fakeDB) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return nil, errors.New("exec error")
+
+// stubEnricher — детерминированная реализация service.Enricher для тестов.
+type stubEnricher struct {
+	res *service.EnrichResult
+	err error
+}
+
+func (s *stubEnricher) Enrich(ctx context.Context, q service.Query) (*service.EnrichResult, error) {
+	return s.res, s.err
 }
 
 // TestGetByID_NotFound проверяет, что обработчик GetByID возвращает статус 404, когда запись не найдена.
 func TestGetByID_NotFound(t *testing.T) {
-	h := &PersonHandler{DB: (*sql.DB)(nil)}
+	h := NewPersonHandler(seedRepo(t), nil)
 	req := httptest.NewRequest(http.MethodGet, "/persons/123", nil)
 	rw := httptest.NewRecorder()
-	h.GetByID(rw, req)
+	route(h).ServeHTTP(rw, req)
 	if rw.Code != http.StatusNotFound {
 		t.Errorf("expected 404, got %d", rw.Code)
 	}
@@ -33,7 +101,7 @@ func TestGetByID_NotFound(t *testing.T) {
 
 // TestCreate_BadPayload проверяет, что обработчик Create возвращает статус 400, когда получает некорректный JSON.
 func TestCreate_BadPayload(t *testing.T) {
-	h := &PersonHandler{DB: (*sql.DB)(nil)}
+	h := NewPersonHandler(nil, nil)
 	req := httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{invalid json}`))
 	rw := httptest.NewRecorder()
 	h.Create(rw, req)
@@ -41,3 +109,605 @@ func TestCreate_BadPayload(t *testing.T) {
 		t.Errorf("expected 400, got %d", rw.Code)
 	}
 }
+
+// TestCreate_EnrichError проверяет, что обработчик Create возвращает статус 500, когда обогащение завершилось ошибкой.
+func TestCreate_EnrichError(t *testing.T) {
+	h := NewPersonHandler(nil, &stubEnricher{err: errors.New("upstream down")})
+	req := httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`))
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+	if rw.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rw.Code)
+	}
+}
+
+// TestCreate_EnrichUnavailable проверяет, что недоступность внешних API отдается как 503.
+func TestCreate_EnrichUnavailable(t *testing.T) {
+	h := NewPersonHandler(nil, &stubEnricher{err: service.ErrRateLimited})
+	req := httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`))
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rw.Code)
+	}
+}
+
+// TestCreate_InvalidCountryHint проверяет, что некорректный country_hint отклоняется со статусом 422 до обогащения.
+func TestCreate_InvalidCountryHint(t *testing.T) {
+	h := NewPersonHandler(nil, &stubEnricher{err: errors.New("must not be called")})
+	req := httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov","country_hint":"Russia"}`))
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+	if rw.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d", rw.Code)
+	}
+}
+
+// TestCreate_Validation проверяет, что некорректные поля отклоняются со статусом 422 до обогащения,
+// а в ответе перечислены все ошибки с кодами по полям.
+func TestCreate_Validation(t *testing.T) {
+	cases := []struct {
+		body string
+		want []validation.FieldError
+	}{
+		{`{"name":" ","surname":"` + strings.Repeat("a", 101) + `","patronymic":"Ivanovich2"}`, []validation.FieldError{
+			{Field: "name", Code: validation.CodeRequired},
+			{Field: "surname", Code: validation.CodeTooLong},
+			{Field: "patronymic", Code: validation.CodeInvalidChars},
+		}},
+		{`{"name":"Ivan","surname":"Ivanov","nickname":"Vanya"}`, []validation.FieldError{
+			{Field: "nickname", Code: validation.CodeUnknownField},
+		}},
+		{`{"name":"Ivan","surname":42}`, []validation.FieldError{
+			{Field: "surname", Code: validation.CodeInvalidType},
+		}},
+	}
+	for _, c := range cases {
+		h := NewPersonHandler(nil, &stubEnricher{err: errors.New("must not be called")})
+		rw := httptest.NewRecorder()
+		h.Create(rw, httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(c.body)))
+		if rw.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected 422, got %d", c.body, rw.Code)
+			continue
+		}
+
+		var resp struct{ Errors []validation.FieldError }
+		if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Errors) != len(c.want) {
+			t.Errorf("%s: expected %d errors, got %+v", c.body, len(c.want), resp.Errors)
+			continue
+		}
+		for i, want := range c.want {
+			if got := resp.Errors[i]; got.Field != want.Field || got.Code != want.Code || got.Message == "" {
+				t.Errorf("%s: expected %s/%s, got %+v", c.body, want.Field, want.Code, got)
+			}
+		}
+	}
+}
+
+// TestCreate_EnrichTimeout проверяет, что превышение срока обогащения отдается как 504.
+func TestCreate_EnrichTimeout(t *testing.T) {
+	h := NewPersonHandler(nil, &stubEnricher{err: context.DeadlineExceeded})
+	req := httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`))
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+	if rw.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", rw.Code)
+	}
+}
+
+// TestCreate_ClientCancelled проверяет, что при отключении клиента запись не создается:
+// обработчик не обращается к хранилищу (Repo равен nil) и не пишет ответ.
+func TestCreate_ClientCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h := NewPersonHandler(nil, &stubEnricher{err: context.Canceled})
+	req := httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`)).WithContext(ctx)
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+	if rw.Body.Len() != 0 {
+		t.Errorf("expected no response body, got %q", rw.Body.String())
+	}
+}
+
+// TestUpdate_InvalidOverride проверяет, что некорректные ручные значения и блокировки отклоняются со статусом 422 до обращения к БД.
+func TestUpdate_InvalidOverride(t *testing.T) {
+	for _, body := range []string{
+		`{"name":"Ivan","surname":"Ivanov","gender":"unknown"}`,
+		`{"name":"Ivan","surname":"Ivanov","nationality":"Russia"}`,
+		`{"name":"Ivan","surname":"Ivanov","unlock":["surname"]}`,
+		`{"name":"Ivan","surname":"Ivanov","age":30,"unlock":["age"]}`,
+	} {
+		h := NewPersonHandler(nil, nil)
+		req := httptest.NewRequest(http.MethodPut, "/persons/1", bytes.NewBufferString(body))
+		rw := httptest.NewRecorder()
+		route(h).ServeHTTP(rw, req)
+		if rw.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected 422, got %d", body, rw.Code)
+		}
+	}
+}
+
+// TestApplyEnrichment_RespectsLocks проверяет, что повторное обогащение после смены имени
+// не перезаписывает атрибуты, которые останутся заблокированными, и их выборки, и учитывает снимаемые блокировки.
+func TestApplyEnrichment_RespectsLocks(t *testing.T) {
+	oldAge, oldGender, oldCount := 50, "female", 7
+	p := model.Person{Age: &oldAge, AgeSampleCount: &oldCount, Gender: &oldGender, LockedFields: []string{"age", "gender"}}
+
+	newAge, newGender, newNationality, newCount := 30, "male", "RU", 900
+	info := &service.EnrichResult{
+		Age: &newAge, AgeCount: &newCount, Gender: &newGender, GenderCount: &newCount, Nationality: &newNationality, NationalityCount: &newCount,
+		Provenance: model.Provenance{
+			"age":         {Source: model.SourceAPI},
+			"gender":      {Source: model.SourceAPI},
+			"nationality": {Source: model.SourceAPI},
+		},
+	}
+
+	req := personUpdate{Unlock: []string{"gender"}}
+	prov := applyEnrichment(&p, info, lockedAfter(p.LockedFields, req))
+
+	if p.Age == nil || *p.Age != 50 || p.AgeSampleCount == nil || *p.AgeSampleCount != 7 {
+		t.Errorf("expected locked age to stay 50 with its sample count, got %v, %v", p.Age, p.AgeSampleCount)
+	}
+	if p.GenderSampleCount == nil || *p.GenderSampleCount != 900 || p.NationalitySampleCount == nil || *p.NationalitySampleCount != 900 {
+		t.Errorf("expected unlocked sample counts refreshed, got %v, %v", p.GenderSampleCount, p.NationalitySampleCount)
+	}
+	if p.Gender == nil || *p.Gender != "male" || p.Nationality == nil || *p.Nationality != "RU" {
+		t.Errorf("expected unlocked fields to be refreshed, got gender=%v nationality=%v", p.Gender, p.Nationality)
+	}
+	if _, ok := prov["age"]; ok || len(prov) != 2 {
+		t.Errorf("expected provenance only for refreshed fields, got %+v", prov)
+	}
+	if len(info.Provenance) != 3 {
+		t.Error("expected enrichment result to stay unchanged")
+	}
+}
+
+// TestCreate_QuotaExhausted проверяет, что при исчерпанной квоте отдается 503 с заголовком Retry-After.
+func TestCreate_QuotaExhausted(t *testing.T) {
+	err := &service.QuotaError{Provider: "agify", ResetAt: time.Now().Add(time.Hour)}
+	h := NewPersonHandler(nil, &stubEnricher{err: err})
+	req := httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`))
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rw.Code)
+	}
+	if ra := rw.Header().Get("Retry-After"); ra != "3600" {
+		t.Errorf("expected Retry-After 3600, got %q", ra)
+	}
+}
+
+// TestCreate_Success проверяет, что обогащенная запись сохраняется в хранилище вместе с происхождением атрибутов.
+func TestCreate_Success(t *testing.T) {
+	age, gender := 42, "male"
+	repo := seedRepo(t)
+	h := NewPersonHandler(repo, &stubEnricher{res: &service.EnrichResult{
+		Age: &age, Gender: &gender,
+		Provenance: model.Provenance{"age": {Source: model.SourceAPI}, "gender": {Source: model.SourceAPI}},
+	}})
+	req := httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`))
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rw.Code)
+	}
+
+	var got model.Person
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	stored := mustGet(t, repo, got.ID)
+	if stored.Age == nil || *stored.Age != 42 || stored.EnrichmentStatus != model.EnrichmentDone {
+		t.Errorf("unexpected stored person %+v", stored)
+	}
+	if got.Message != "Ivan Ivanov: age 42, gender male, nationality unknown" {
+		t.Errorf("unexpected message %q", got.Message)
+	}
+	if prov := mustProvenance(t, repo, got.ID); len(prov) != 2 {
+		t.Errorf("expected provenance for 2 fields, got %+v", prov)
+	}
+}
+
+// TestCreate_ReadOnlyFields проверяет, что поля, которые задает сервер (блокировки, статус обогащения, ID),
+// отклоняются со статусом 422 и кодом read_only, а запись не создается.
+func TestCreate_ReadOnlyFields(t *testing.T) {
+	repo := seedRepo(t)
+	h := NewPersonHandler(repo, &stubEnricher{err: errors.New("must not be called")})
+	body := `{"name":"Ivan","surname":"Ivanov","locked_fields":["age","bogus"],"id":77,"enrichment_status":"dead"}`
+	rw := httptest.NewRecorder()
+	h.Create(rw, httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(body)))
+	if rw.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rw.Code, rw.Body)
+	}
+
+	var resp struct{ Errors []validation.FieldError }
+	if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, fe := range resp.Errors {
+		got = append(got, fe.Field+":"+fe.Code)
+	}
+	if want := "enrichment_status:read_only,id:read_only,locked_fields:read_only"; strings.Join(got, ",") != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+	if persons, _ := repo.List(context.Background(), repository.ListFilter{}); len(persons) != 0 {
+		t.Errorf("person must not be created, got %+v", persons)
+	}
+}
+
+// TestCreate_AsyncRejectsAttributes проверяет, что при асинхронном обогащении атрибуты из тела POST
+// не сохраняются в обход проверки ручных значений: они задаются только в PUT и PATCH.
+func TestCreate_AsyncRejectsAttributes(t *testing.T) {
+	repo := seedRepo(t)
+	h := NewPersonHandler(repo, nil)
+	h.Async = true
+	rw := httptest.NewRecorder()
+	h.Create(rw, httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov","age":-5}`)))
+	if rw.Code != http.StatusUnprocessableEntity || !strings.Contains(rw.Body.String(), `"code":"read_only"`) {
+		t.Fatalf("expected 422 read_only, got %d: %s", rw.Code, rw.Body)
+	}
+	if persons, _ := repo.List(context.Background(), repository.ListFilter{}); len(persons) != 0 {
+		t.Errorf("person must not be created, got %+v", persons)
+	}
+}
+
+// TestUpdate_ReadOnlyFields проверяет, что PUT, как и PATCH, отклоняет поля, которые задает сервер.
+func TestUpdate_ReadOnlyFields(t *testing.T) {
+	repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov"})
+	h := NewPersonHandler(repo, nil)
+	rw := httptest.NewRecorder()
+	body := `{"name":"Ivan","surname":"Petrov","locked_fields":["gender"]}`
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/persons/1", bytes.NewBufferString(body)))
+	if rw.Code != http.StatusUnprocessableEntity || !strings.Contains(rw.Body.String(), `"field":"locked_fields","code":"read_only"`) {
+		t.Fatalf("expected 422 read_only, got %d: %s", rw.Code, rw.Body)
+	}
+	if p := mustGet(t, repo, 1); p.Surname != "Ivanov" || len(p.LockedFields) != 0 {
+		t.Errorf("person must not change, got %+v", p)
+	}
+}
+
+// TestCreate_StorageError проверяет, что ошибка хранилища отдается как 500.
+func TestCreate_StorageError(t *testing.T) {
+	h := NewPersonHandler(failingRepo{}, &stubEnricher{res: &service.EnrichResult{}})
+	req := httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`))
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+	if rw.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rw.Code)
+	}
+}
+
+// TestGetByID_Found проверяет, что найденная запись отдается с текстовым описанием.
+func TestGetByID_Found(t *testing.T) {
+	h := NewPersonHandler(seedRepo(t, model.Person{Name: "Ivan"}, model.Person{Name: "Anna", Surname: "Petrova"}), nil)
+	req := httptest.NewRequest(http.MethodGet, "/persons/2", nil)
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rw.Code)
+	}
+	var got model.Person
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 2 || got.Message != "Anna Petrova: age unknown, gender unknown, nationality unknown" {
+		t.Errorf("unexpected person %+v", got)
+	}
+}
+
+// TestGetAll_Filter проверяет, что фильтр по имени передается хранилищу.
+func TestGetAll_Filter(t *testing.T) {
+	h := NewPersonHandler(seedRepo(t, model.Person{Name: "Anna"}, model.Person{Name: "Ivan"}), nil)
+	req := httptest.NewRequest(http.MethodGet, "/persons?name=iva", nil)
+	rw := httptest.NewRecorder()
+	h.GetAll(rw, req)
+
+	var got []model.Person
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("expected only Ivan, got %+v", got)
+	}
+}
+
+// TestUpdate_NameChangeRefreshes проверяет, что смена имени обогащает запись заново
+// с сохранением заблокированных атрибутов, а ручное значение блокируется.
+func TestUpdate_NameChangeRefreshes(t *testing.T) {
+	oldAge, newAge, newGender := 50, 30, "female"
+	repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov", Age: &oldAge, LockedFields: []string{"age"}})
+	h := NewPersonHandler(repo, &stubEnricher{res: &service.EnrichResult{Age: &newAge, Gender: &newGender}})
+	req := httptest.NewRequest(http.MethodPut, "/persons/1", bytes.NewBufferString(`{"name":"Anna","surname":"Ivanova","nationality":"ru"}`))
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, req)
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rw.Code, rw.Body)
+	}
+	if got := rw.Header().Get("X-Enrichment"); got != "refreshed" {
+		t.Errorf("expected X-Enrichment refreshed, got %q", got)
+	}
+
+	p := mustGet(t, repo, 1)
+	if p.Name != "Anna" || *p.Age != 50 || p.Gender == nil || *p.Gender != "female" || p.Nationality == nil || *p.Nationality != "RU" {
+		t.Errorf("unexpected stored person %+v", p)
+	}
+	if strings.Join(p.LockedFields, ",") != "age,nationality" {
+		t.Errorf("expected age and nationality locked, got %v", p.LockedFields)
+	}
+	if prov := mustProvenance(t, repo, 1); prov["nationality"].Source != model.SourceManual {
+		t.Errorf("expected manual provenance for nationality, got %+v", prov)
+	}
+}
+
+// TestUpdate_EnrichErrorKeepsRecord проверяет, что при ошибке обогащения запись не меняется.
+func TestUpdate_EnrichErrorKeepsRecord(t *testing.T) {
+	repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov"})
+	h := NewPersonHandler(repo, &stubEnricher{err: service.ErrUpstreamUnavailable})
+	req := httptest.NewRequest(http.MethodPut, "/persons/1", bytes.NewBufferString(`{"name":"Anna","surname":"Ivanova"}`))
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, req)
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rw.Code)
+	}
+	if p := mustGet(t, repo, 1); p.Name != "Ivan" {
+		t.Errorf("expected record to stay unchanged, got %+v", p)
+	}
+}
+
+// TestUpdate_EnrichErrorQueues проверяет, что при доступной очереди ошибка обогащения не отменяет смену имени:
+// изменение сохраняется, а повторное обогащение ставится в очередь.
+func TestUpdate_EnrichErrorQueues(t *testing.T) {
+	repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov"})
+	h := NewPersonHandler(repo, &stubEnricher{err: service.ErrUpstreamUnavailable})
+	h.QueueOnError = true
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/persons/1", bytes.NewBufferString(`{"name":"Anna","surname":"Ivanova"}`)))
+	if rw.Code != http.StatusNoContent || rw.Header().Get("X-Enrichment") != "queued" {
+		t.Fatalf("expected 204 queued, got %d %q: %s", rw.Code, rw.Header().Get("X-Enrichment"), rw.Body)
+	}
+	if p := mustGet(t, repo, 1); p.Name != "Anna" || p.EnrichmentStatus != model.EnrichmentPending {
+		t.Errorf("expected renamed pending person, got %+v", p)
+	}
+}
+
+// lockProbeEnricher во время обогащения обновляет ту же запись и сообщает, удалось ли это,
+// то есть не держит ли обработчик блокировку записи на время вызова внешних API.
+type lockProbeEnricher struct {
+	repo   repository.PersonRepository
+	locked bool
+}
+
+func (e *lockProbeEnricher) Enrich(ctx context.Context, q service.Query) (*service.EnrichResult, error) {
+	done := make(chan struct{})
+	go func() {
+		e.repo.Update(ctx, 1, func(*model.Person, repository.History) (repository.Change, error) { return repository.Change{}, nil })
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		e.locked = true
+	}
+	return &service.EnrichResult{}, nil
+}
+
+// TestUpdate_EnrichesOutsideLock проверяет, что повторное обогащение выполняется вне блокировки записи.
+func TestUpdate_EnrichesOutsideLock(t *testing.T) {
+	repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov"})
+	enricher := &lockProbeEnricher{repo: repo}
+	h := NewPersonHandler(repo, enricher)
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/persons/1", bytes.NewBufferString(`{"name":"Anna","surname":"Ivanova"}`)))
+	if rw.Code != http.StatusNoContent || rw.Header().Get("X-Enrichment") != "refreshed" {
+		t.Fatalf("expected 204 refreshed, got %d %q: %s", rw.Code, rw.Header().Get("X-Enrichment"), rw.Body)
+	}
+	if enricher.locked {
+		t.Error("record was locked during enrichment")
+	}
+}
+
+// TestUpdateDelete_NotFound проверяет, что Update и Delete отдают 404 для отсутствующей записи.
+func TestUpdateDelete_NotFound(t *testing.T) {
+	h := NewPersonHandler(seedRepo(t), nil)
+
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/persons/5", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`)))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("update: expected 404, got %d", rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodDelete, "/persons/5", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("delete: expected 404, got %d", rw.Code)
+	}
+}
+
+// TestDelete_Success проверяет удаление записи.
+func TestDelete_Success(t *testing.T) {
+	repo := seedRepo(t, model.Person{Name: "Ivan"})
+	h := NewPersonHandler(repo, nil)
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodDelete, "/persons/1", nil))
+	if rw.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rw.Code)
+	}
+	if _, err := repo.Get(context.Background(), 1); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected person to be deleted, got %v", err)
+	}
+}
+
+// TestHandlers_StorageError проверяет, что ошибки хранилища отдаются как 500 всеми обработчиками.
+func TestHandlers_StorageError(t *testing.T) {
+	h := NewPersonHandler(failingRepo{}, nil)
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/persons", nil),
+		httptest.NewRequest(http.MethodGet, "/persons/1", nil),
+		httptest.NewRequest(http.MethodGet, "/persons/1/enrichment", nil),
+		httptest.NewRequest(http.MethodGet, "/persons/1/history", nil),
+		httptest.NewRequest(http.MethodPut, "/persons/1", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`)),
+		httptest.NewRequest(http.MethodDelete, "/persons/1", nil),
+	} {
+		rw := httptest.NewRecorder()
+		route(h).ServeHTTP(rw, req)
+		if rw.Code != http.StatusInternalServerError {
+			t.Errorf("%s %s: expected 500, got %d", req.Method, req.URL.Path, rw.Code)
+		}
+	}
+}
+
+// TestRoutes_IDAndMethod проверяет разбор {id}, 404 на неизвестные пути и 405 с заголовком Allow.
+func TestRoutes_IDAndMethod(t *testing.T) {
+	h := NewPersonHandler(seedRepo(t, model.Person{Name: "Ivan"}), nil)
+	cases := []struct {
+		method, target string
+		want           int
+		allow          string
+	}{
+		{http.MethodGet, "/persons/1", http.StatusOK, ""},
+		{http.MethodGet, "/persons/abc", http.StatusBadRequest, ""},
+		{http.MethodPut, "/persons/abc", http.StatusBadRequest, ""},
+		{http.MethodDelete, "/persons/abc", http.StatusBadRequest, ""},
+		{http.MethodGet, "/persons/1/extra", http.StatusNotFound, ""},
+		{http.MethodPost, "/persons/1", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, PATCH, PUT"},
+		{http.MethodDelete, "/persons", http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+		{http.MethodPost, "/persons/1/history", http.StatusMethodNotAllowed, "GET, HEAD"},
+	}
+	for _, c := range cases {
+		rw := httptest.NewRecorder()
+		route(h).ServeHTTP(rw, httptest.NewRequest(c.method, c.target, nil))
+		if rw.Code != c.want {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.target, c.want, rw.Code)
+		}
+		if allow := rw.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", c.method, c.target, c.allow, allow)
+		}
+	}
+}
+
+// TestHistory проверяет, что история изменений атрибутов отдается в порядке записи.
+func TestHistory(t *testing.T) {
+	age := 42
+	repo := seedRepo(t)
+	h := NewPersonHandler(repo, &stubEnricher{res: &service.EnrichResult{
+		Age: &age, Provenance: model.Provenance{"age": {Source: model.SourceAPI, Inferred: true}},
+	}})
+
+	rw := httptest.NewRecorder()
+	h.Create(rw, httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`)))
+	rw = httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/persons/1", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov","age":30}`)))
+
+	rw = httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/persons/1/history", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rw.Code)
+	}
+	var history []model.HistoryEntry
+	if err := json.NewDecoder(rw.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || *history[0].Value != "42" || history[1].Source != model.SourceManual || *history[1].Value != "30" {
+		t.Errorf("unexpected history %+v", history)
+	}
+
+	rw = httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/persons/2/history", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown person, got %d", rw.Code)
+	}
+}
+
+// TestCreate_Async проверяет, что в асинхронном режиме запись сохраняется без обогащения
+// со статусом pending и ответом 202, а состояние обогащения доступно по /enrichment.
+func TestCreate_Async(t *testing.T) {
+	repo := seedRepo(t)
+	h := NewPersonHandler(repo, &stubEnricher{err: errors.New("must not be called")})
+	h.Async = true
+
+	rw := httptest.NewRecorder()
+	h.Create(rw, httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`)))
+	if rw.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/persons/1/enrichment", nil))
+	var st model.EnrichmentState
+	if err := json.NewDecoder(rw.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if rw.Code != http.StatusOK || st.Status != model.EnrichmentPending {
+		t.Errorf("expected pending state, got %d %+v", rw.Code, st)
+	}
+
+	rw = httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/persons/9/enrichment", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown person, got %d", rw.Code)
+	}
+}
+
+// TestUpdate_UnchangedRoundTrip проверяет, что запись, прочитанная через GET и отправленная обратно с правкой
+// фамилии, не блокирует предсказанные атрибуты, а измененное значение блокируется.
+func TestUpdate_UnchangedRoundTrip(t *testing.T) {
+	age, gender, nationality := 42, "male", "RU"
+	repo := seedRepo(t, model.Person{Name: "Ivan", Surname: "Ivanov", Age: &age, Gender: &gender, Nationality: &nationality})
+	h := NewPersonHandler(repo, &stubEnricher{err: errStorage})
+
+	rw := httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/persons/1",
+		bytes.NewBufferString(`{"name":"Ivan","surname":"Petrov","age":42,"gender":"male","nationality":"ru"}`)))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rw.Code, rw.Body)
+	}
+	if p := mustGet(t, repo, 1); p.Surname != "Petrov" || len(p.LockedFields) != 0 {
+		t.Errorf("expected surname changed and nothing locked, got %+v", p)
+	}
+
+	rw = httptest.NewRecorder()
+	route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/persons/1",
+		bytes.NewBufferString(`{"name":"Ivan","surname":"Petrov","age":43,"gender":"male"}`)))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rw.Code, rw.Body)
+	}
+	if p := mustGet(t, repo, 1); *p.Age != 43 || strings.Join(p.LockedFields, ",") != "age" {
+		t.Errorf("expected only changed age locked, got age=%d locked=%v", *p.Age, p.LockedFields)
+	}
+}
+
+// TestUpdate_UnlockRestoresInferred проверяет, что снятие блокировки возвращает последнее предсказанное значение.
+func TestUpdate_UnlockRestoresInferred(t *testing.T) {
+	age := 42
+	repo := seedRepo(t)
+	h := NewPersonHandler(repo, &stubEnricher{res: &service.EnrichResult{
+		Age: &age, Provenance: model.Provenance{"age": {Source: model.SourceAPI, Inferred: true}},
+	}})
+
+	rw := httptest.NewRecorder()
+	h.Create(rw, httptest.NewRequest(http.MethodPost, "/persons", bytes.NewBufferString(`{"name":"Ivan","surname":"Ivanov"}`)))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rw.Code)
+	}
+
+	for _, body := range []string{
+		`{"name":"Ivan","surname":"Ivanov","age":30}`,
+		`{"name":"Ivan","surname":"Ivanov","unlock":["age"]}`,
+	} {
+		rw := httptest.NewRecorder()
+		route(h).ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/persons/1", bytes.NewBufferString(body)))
+		if rw.Code != http.StatusNoContent {
+			t.Fatalf("%s: expected 204, got %d", body, rw.Code)
+		}
+	}
+
+	p := mustGet(t, repo, 1)
+	if p.Age == nil || *p.Age != 42 || len(p.LockedFields) != 0 {
+		t.Errorf("expected inferred age 42 restored and unlocked, got age=%v locked=%v", p.Age, p.LockedFields)
+	}
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Register регистрирует маршруты записей в mux. Шаблоны ServeMux (Go 1.22+) сами отвечают
+// 404 на неизвестные пути и 405 с заголовком Allow на неподдерживаемые методы.
+func (h *PersonHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /persons", logged(h.Create))
+	mux.HandleFunc("GET /persons", logged(h.GetAll))
+	mux.HandleFunc("GET /persons/{id}", logged(h.GetByID))
+	mux.HandleFunc("PUT /persons/{id}", logged(h.Update))
+	mux.HandleFunc("PATCH /persons/{id}", logged(h.Patch))
+	mux.HandleFunc("DELETE /persons/{id}", logged(h.Delete))
+	mux.HandleFunc("GET /persons/{id}/enrichment", logged(h.EnrichmentStatus))
+	mux.HandleFunc("GET /persons/{id}/history", logged(h.History))
+}
+
+// Register регистрирует служебные маршруты в mux. /metrics не логируется,
+// чтобы частые опросы Prometheus не засоряли журнал.
+func (h *AdminHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/cache", logged(h.CacheStats))
+	mux.HandleFunc("GET /admin/breakers", logged(h.Breakers))
+	mux.HandleFunc("GET /admin/quota", logged(h.Quota))
+	mux.HandleFunc("GET /metrics", h.Metrics)
+}
+
+// logged записывает в журнал метод и путь запроса.
+func logged(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Infof("%s %s", r.Method, r.URL.Path)
+		next(w, r)
+	}
+}
+
+// pathID разбирает параметр {id} маршрута; при ошибке отвечает 400 и возвращает false.
+func pathID(w http.ResponseWriter, r *http.Request, caller string) (int, bool) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.WithError(err).Warnf("%s: invalid id %s", caller, idStr)
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
@@ -10,8 +10,11 @@ func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Устанавливаем заголовки для поддержки CORS
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		// Открываем браузеру заголовки ответа, которые читает фронтенд: статус повторного обогащения,
+		// время повтора при недоступности API и поддерживаемые форматы PATCH
+		w.Header().Set("Access-Control-Expose-Headers", "X-Enrichment, Retry-After, Accept-Patch")
 
 		// Если метод запроса - OPTIONS, то возвращаем статус No Content и завершаем обработку запроса
 		if r.Method == http.MethodOptions {
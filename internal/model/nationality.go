@@ -0,0 +1,38 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// NationalityCandidate — страна из ответа Nationalize и ее вероятность.
+type NationalityCandidate struct {
+	CountryID   string  `json:"country_id"`
+	Probability float64 `json:"probability"`
+}
+
+// NationalityCandidates — ранжированный список стран, хранится в столбце JSONB.
+type NationalityCandidates []NationalityCandidate
+
+// Value сериализует список в JSON для записи в PostgreSQL.
+func (c NationalityCandidates) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan разбирает значение столбца JSONB.
+func (c *NationalityCandidates) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	}
+	return fmt.Errorf("cannot scan %T into NationalityCandidates", src)
+}
@@ -2,6 +2,13 @@ package model
 
 import "time"
 
+// Статусы асинхронного обогащения записи.
+const (
+	EnrichmentPending = "pending"
+	EnrichmentDone    = "done"
+	EnrichmentDead    = "dead"
+)
+
 type Person struct {
 	ID          int       `json:"id"`
 	Name        string    `json:"name"`
@@ -12,4 +19,44 @@ type Person struct {
 	Nationality *string   `json:"nationality,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	Message     string    `json:"message"`
+
+	// CountryHint — код страны ISO 3166-1 alpha-2, уточняющий прогноз при обогащении.
+	CountryHint *string `json:"country_hint,omitempty"`
+
+	// MissingFields — поля, которые не удалось обогатить и которые нужно дозаполнить позже.
+	MissingFields []string `json:"missing_fields,omitempty"`
+	// EnrichmentStatus — состояние асинхронного обогащения: pending, done или dead.
+	EnrichmentStatus string `json:"enrichment_status,omitempty"`
+
+	// Достоверность обогащенных значений по данным провайдеров.
+	AgeSampleCount         *int                  `json:"age_sample_count,omitempty"`
+	GenderProbability      *float64              `json:"gender_probability,omitempty"`
+	GenderSampleCount      *int                  `json:"gender_sample_count,omitempty"`
+	NationalityCandidates  NationalityCandidates `json:"nationality_candidates,omitempty"`
+	NationalitySampleCount *int                  `json:"nationality_sample_count,omitempty"`
+	// LowConfidenceFields — поля, значения которых отброшены из-за низкой достоверности.
+	LowConfidenceFields []string `json:"low_confidence_fields,omitempty"`
+
+	// EnrichmentSource — источник обогащения: api или fallback (локальный словарь имен).
+	EnrichmentSource *string `json:"enrichment_source,omitempty"`
+	// FallbackFields — поля, значения которых взяты из локального словаря.
+	FallbackFields []string `json:"fallback_fields,omitempty"`
+
+	// EnrichedAt — время последнего обогащения записи.
+	EnrichedAt *time.Time `json:"enriched_at,omitempty"`
+
+	// LockedFields — атрибуты, заданные вручную; повторное обогащение их не перезаписывает.
+	LockedFields []string `json:"locked_fields,omitempty"`
+
+	// Provenance — происхождение атрибутов; заполняется только в расширенном представлении (?expand=provenance).
+	Provenance Provenance `json:"provenance,omitempty"`
+}
+
+// EnrichmentState описывает ход асинхронного обогащения записи.
+type EnrichmentState struct {
+	ID            int        `json:"id"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastError     *string    `json:"last_error,omitempty"`
 }
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Источники значений атрибутов записи.
+const (
+	// SourceAPI — значение получено от внешнего API (Agify, Genderize, Nationalize).
+	SourceAPI = "api"
+	// SourceCache — значение API взято из кэша обогащения.
+	SourceCache = "cache"
+	// SourceFallback — значение взято из локального словаря имен.
+	SourceFallback = "fallback"
+	// SourceManual — значение задано пользователем.
+	SourceManual = "manual"
+)
+
+// FieldProvenance описывает происхождение значения одного атрибута записи.
+type FieldProvenance struct {
+	// Value — значение атрибута в момент записи; nil, если значение отброшено или неизвестно.
+	Value    *string `json:"value"`
+	Source   string  `json:"source"`
+	Provider string  `json:"provider,omitempty"`
+	// ResponseID — идентификатор запроса, сообщенный провайдером, если он есть.
+	ResponseID string `json:"response_id,omitempty"`
+	// Raw — исходный ответ провайдера для этого имени.
+	Raw json.RawMessage `json:"raw,omitempty"`
+	// Inferred — значение предсказано, а не указано пользователем.
+	Inferred   bool      `json:"inferred"`
+	EnrichedAt time.Time `json:"enriched_at"`
+}
+
+// Provenance — происхождение атрибутов записи по имени поля (age, gender, nationality).
+type Provenance map[string]FieldProvenance
+
+// HistoryEntry — одно изменение атрибута записи в истории (GET /persons/{id}/history).
+type HistoryEntry struct {
+	Field string `json:"field"`
+	FieldProvenance
+}
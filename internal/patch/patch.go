@@ -0,0 +1,305 @@
+// Package patch применяет к JSON-документам JSON Merge Patch (RFC 7396) и JSON Patch (RFC 6902).
+// Документы представлены так, как их разбирает encoding/json в interface{}:
+// map[string]interface{}, []interface{} и скалярные значения.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPath возвращается, если путь операции не указывает на существующее значение или некорректен.
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrTestFailed возвращается, если операция test не совпала с текущим значением.
+	ErrTestFailed = errors.New("test operation failed")
+)
+
+// Merge применяет merge patch к target и возвращает результат (RFC 7396, раздел 2).
+// null в patch удаляет член объекта; patch, не являющийся объектом, заменяет target целиком.
+func Merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	out := make(map[string]interface{}, len(t))
+	for k, v := range t {
+		out[k] = v
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(out, k)
+			continue
+		}
+		out[k] = Merge(out[k], v)
+	}
+	return out
+}
+
+// Operation — одна операция JSON Patch.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Validate проверяет название операции, пути и наличие value.
+func (o Operation) Validate() error {
+	switch o.Op {
+	case "add", "replace", "test":
+		if o.Value == nil {
+			return fmt.Errorf("%s %q: missing value", o.Op, o.Path)
+		}
+	case "move", "copy":
+		if _, err := parsePointer(o.From); err != nil {
+			return fmt.Errorf("%s %q: from: %w", o.Op, o.Path, err)
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("unknown operation %q", o.Op)
+	}
+	if _, err := parsePointer(o.Path); err != nil {
+		return fmt.Errorf("%s %q: %w", o.Op, o.Path, err)
+	}
+	return nil
+}
+
+// Apply последовательно применяет операции к doc (RFC 6902). При ошибке любой операции
+// возвращается ошибка, а doc не меняется: операции применяются к копии.
+func Apply(doc interface{}, ops []Operation) (interface{}, error) {
+	doc = deepCopy(doc)
+	for _, op := range ops {
+		if err := op.Validate(); err != nil {
+			return nil, err
+		}
+		path, _ := parsePointer(op.Path)
+
+		var err error
+		switch op.Op {
+		case "add", "replace", "test":
+			var value interface{}
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, fmt.Errorf("%s %q: invalid value: %w", op.Op, op.Path, err)
+			}
+			switch op.Op {
+			case "add":
+				doc, err = add(doc, path, value)
+			case "replace":
+				if len(path) == 0 {
+					doc = value
+				} else if doc, _, err = remove(doc, path); err == nil {
+					doc, err = add(doc, path, value)
+				}
+			case "test":
+				var current interface{}
+				if current, err = get(doc, path); err == nil && !reflect.DeepEqual(current, value) {
+					err = ErrTestFailed
+				}
+			}
+		case "remove":
+			doc, _, err = remove(doc, path)
+		case "move":
+			from, _ := parsePointer(op.From)
+			if isPrefix(from, path) && len(from) < len(path) {
+				return nil, fmt.Errorf("move %q: cannot move into own child %q: %w", op.From, op.Path, ErrInvalidPath)
+			}
+			var value interface{}
+			if doc, value, err = remove(doc, from); err == nil {
+				doc, err = add(doc, path, value)
+			}
+		case "copy":
+			from, _ := parsePointer(op.From)
+			var value interface{}
+			if value, err = get(doc, from); err == nil {
+				doc, err = add(doc, path, deepCopy(value))
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %w", op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer разбирает JSON Pointer (RFC 6901) на токены.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /: %w", p, ErrInvalidPath)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// RootMember возвращает первый токен пути JSON Pointer — член корневого объекта, который затрагивает путь.
+// Для пути "" (весь документ) возвращается пустая строка.
+func RootMember(p string) (string, error) {
+	tokens, err := parsePointer(p)
+	if err != nil || len(tokens) == 0 {
+		return "", err
+	}
+	return tokens[0], nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get возвращает значение по пути.
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found: %w", token, ErrInvalidPath)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse %q: %w", token, ErrInvalidPath)
+		}
+	}
+	return doc, nil
+}
+
+// add добавляет value по пути и возвращает новый корень документа.
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[token] = value
+		return doc, nil
+	case []interface{}:
+		i := len(node)
+		if token != "-" {
+			if i, err = arrayIndex(token, len(node)); err != nil {
+				return nil, err
+			}
+		}
+		node = append(node, nil)
+		copy(node[i+1:], node[i:])
+		node[i] = value
+		return set(doc, path[:len(path)-1], node)
+	}
+	return nil, fmt.Errorf("cannot add to %q: %w", token, ErrInvalidPath)
+}
+
+// remove удаляет значение по пути и возвращает новый корень документа и удаленное значение.
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole document: %w", ErrInvalidPath)
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	token := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		value, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("member %q not found: %w", token, ErrInvalidPath)
+		}
+		delete(node, token)
+		return doc, value, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[i]
+		node = append(node[:i:i], node[i+1:]...)
+		doc, err = set(doc, path[:len(path)-1], node)
+		return doc, value, err
+	}
+	return nil, nil, fmt.Errorf("cannot remove %q: %w", token, ErrInvalidPath)
+}
+
+// set заменяет значение по существующему пути; нужен для массивов, которые меняются при вставке и удалении.
+func set(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[token] = value
+	case []interface{}:
+		i, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = value
+	}
+	return doc, nil
+}
+
+// arrayIndex разбирает индекс массива не больше limit.
+func arrayIndex(token string, limit int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > limit || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q: %w", token, ErrInvalidPath)
+	}
+	return i, nil
+}
+
+// deepCopy копирует объекты и массивы документа.
+func deepCopy(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			out[k] = deepCopy(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(node))
+		for i, v := range node {
+			out[i] = deepCopy(v)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,365 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+
+	"effect/internal/audit"
+	"effect/internal/model"
+	"effect/internal/service"
+)
+
+// Options задает параметры пула воркеров обогащения.
+type Options struct {
+	// Workers — количество параллельных воркеров.
+	Workers int
+	// PollInterval — пауза между опросами очереди, когда задач нет.
+	PollInterval time.Duration
+	// MaxAttempts — число попыток, после которого запись переводится в статус dead.
+	MaxAttempts int
+	// RetryBackoff — базовая задержка перед повтором, удваивается с каждой попыткой.
+	RetryBackoff time.Duration
+	// BatchSize — сколько записей воркер захватывает и обогащает за один раз.
+	BatchSize int
+	// Lease — срок аренды захваченных записей; по его истечении запись может захватить другой воркер.
+	Lease time.Duration
+}
+
+// DefaultLease — срок аренды захваченных записей по умолчанию.
+const DefaultLease = 5 * time.Minute
+
+// Pool обрабатывает записи persons со статусом pending, используя PostgreSQL как очередь.
+// Записи захватываются через SELECT ... FOR UPDATE SKIP LOCKED и выдаются воркеру в аренду
+// (enrichment_next_at), поэтому несколько воркеров (и несколько экземпляров сервиса)
+// не обрабатывают одну запись одновременно.
+type Pool struct {
+	DB       *sql.DB
+	Enricher service.Enricher
+	Opts     Options
+}
+
+// NewPool создает пул воркеров; нулевые значения опций заменяются значениями по умолчанию.
+func NewPool(db *sql.DB, enricher service.Enricher, opts Options) *Pool {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 30 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = service.MaxBatchSize
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = DefaultLease
+	}
+	return &Pool{DB: db, Enricher: enricher, Opts: opts}
+}
+
+// Run запускает воркеров и блокируется до отмены ctx.
+func (p *Pool) Run(ctx context.Context) {
+	log.Infof("queue.Pool: starting %d enrichment workers", p.Opts.Workers)
+
+	var wg sync.WaitGroup
+	wg.Add(p.Opts.Workers)
+	for i := 0; i < p.Opts.Workers; i++ {
+		go func(id int) {
+			defer wg.Done()
+			p.worker(ctx, id)
+		}(i)
+	}
+	wg.Wait()
+
+	log.Info("queue.Pool: enrichment workers stopped")
+}
+
+func (p *Pool) worker(ctx context.Context, id int) {
+	for {
+		processed, err := p.ProcessBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.WithError(err).Errorf("queue.Pool: worker %d failed to process job", id)
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.Opts.PollInterval):
+		}
+	}
+}
+
+// job — захваченная воркером запись.
+type job struct {
+	id       int
+	query    service.Query
+	attempts int
+}
+
+// ProcessBatch захватывает до BatchSize готовых к обработке записей, обогащает их
+// пакетно и сохраняет результаты. Возвращает false, если очередь пуста.
+//
+// Внешние API вызываются без открытой транзакции: записи захватываются в аренду на Lease
+// коротким запросом, а результаты сохраняются второй транзакцией и только для записей,
+// имя которых за время обогащения не изменилось.
+func (p *Pool) ProcessBatch(ctx context.Context) (bool, error) {
+	jobs, err := p.claim(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(jobs) == 0 {
+		return false, nil
+	}
+	queries := make([]service.Query, len(jobs))
+	for i, j := range jobs {
+		queries[i] = j.query
+	}
+
+	log.Debugf("queue.Pool: enriching batch of %d persons", len(jobs))
+	results, enrichErr := service.EnrichAll(ctx, p.Enricher, queries)
+	partial, isPartial := service.AsPartial(enrichErr)
+	if enrichErr != nil && ctx.Err() != nil {
+		// сервис останавливается: аренда снимается, записи останутся pending и будут обработаны позже
+		release(p.DB, jobIDs(jobs))
+		return true, ctx.Err()
+	}
+	if resetAt, ok := service.QuotaResetAt(enrichErr); ok && !isPartial {
+		log.WithError(enrichErr).Warnf("queue.Pool: postponing %d persons until %s", len(jobs), resetAt.Format(time.RFC3339))
+	}
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return true, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	saved := 0
+	for _, j := range jobs {
+		ok, err := unchanged(ctx, tx, j.id, j.query, model.EnrichmentPending)
+		if err != nil {
+			return true, err
+		}
+		if !ok {
+			log.Infof("queue.Pool: person id=%d changed during enrichment, result discarded", j.id)
+			continue
+		}
+
+		switch {
+		case isPartial && partial.Failed[j.query]:
+			// имя не удалось обогатить ни через API, ни из словаря: запись остается pending
+			err = p.retry(ctx, tx, j, partial.Err)
+		case enrichErr != nil && !isPartial:
+			// исчерпанная квота не считается неудачной попыткой: записи откладываются до ее сброса
+			err = p.retry(ctx, tx, j, enrichErr)
+		default:
+			res := results[j.query]
+			if res == nil {
+				res = &service.EnrichResult{}
+			}
+			err = p.save(ctx, tx, j, res)
+			saved++
+		}
+		if err != nil {
+			return true, err
+		}
+	}
+
+	if saved > 0 {
+		log.Infof("queue.Pool: enriched %d persons", saved)
+	}
+	return true, tx.Commit()
+}
+
+// claim выдает воркеру в аренду до BatchSize готовых к обработке записей в порядке id.
+// Запрос выполняется вне транзакции воркера, поэтому строки не остаются заблокированными на время обогащения.
+func (p *Pool) claim(ctx context.Context) ([]job, error) {
+	rows, err := p.DB.QueryContext(ctx, `
+		UPDATE persons SET enrichment_next_at = NOW() + make_interval(secs => $3)
+		WHERE id IN (
+			SELECT id FROM persons
+			WHERE enrichment_status = $1
+			  AND (enrichment_next_at IS NULL OR enrichment_next_at <= NOW())
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, name, COALESCE(country_hint, ''), enrichment_attempts`,
+		model.EnrichmentPending, p.Opts.BatchSize, p.Opts.Lease.Seconds(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []job
+	for rows.Next() {
+		var j job
+		if err := rows.Scan(&j.id, &j.query.Name, &j.query.CountryID, &j.attempts); err != nil {
+			return nil, fmt.Errorf("scan job: %w", err)
+		}
+		j.attempts++
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claim jobs: %w", err)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].id < jobs[b].id })
+	return jobs, nil
+}
+
+func jobIDs(jobs []job) []int64 {
+	ids := make([]int64, len(jobs))
+	for i, j := range jobs {
+		ids[i] = int64(j.id)
+	}
+	return ids
+}
+
+// unchanged блокирует запись id до конца tx и сообщает, можно ли сохранить результат ее обогащения
+// по запросу q: запись не удалена, ее статус равен status, а имя и страна не изменились после захвата.
+func unchanged(ctx context.Context, tx *sql.Tx, id int, q service.Query, status string) (bool, error) {
+	var ok bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT name = $2 AND COALESCE(country_hint, '') = $3 AND enrichment_status = $4
+		FROM persons WHERE id=$1 FOR UPDATE`,
+		id, q.Name, q.CountryID, status,
+	).Scan(&ok)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("lock person id=%d: %w", id, err)
+	}
+	return ok, nil
+}
+
+// release снимает аренду с записей ids, не дожидаясь ее истечения. Вызывается, когда обогащение
+// прервано, в том числе при остановке сервиса, поэтому не зависит от контекста запроса.
+func release(db *sql.DB, ids []int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, `UPDATE persons SET enrichment_next_at=NULL WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		log.WithError(err).Warnf("queue: failed to release %d claimed persons", len(ids))
+	}
+}
+
+// save записывает результат обогащения и помечает запись как обработанную.
+func (p *Pool) save(ctx context.Context, tx *sql.Tx, j job, res *service.EnrichResult) error {
+	old, err := storeResult(ctx, tx, j.id, &j.attempts, res)
+	if err != nil {
+		return err
+	}
+	person := model.Person{ID: j.id, Age: res.Age, Gender: res.Gender, Nationality: res.Nationality}
+	return audit.Record(ctx, tx, person, audit.Unlocked(res.Provenance, old.LockedFields))
+}
+
+// storeResult записывает результат обогащения записи id и помечает ее как обработанную.
+// Атрибуты из locked_fields, заданные вручную, и не полученные (res.Missing) не перезаписываются
+// вместе с их выборками и вероятностями;
+// если attempts равен nil, счетчик попыток не меняется. Возвращает прежние age, gender, nationality и блокировки записи.
+func storeResult(ctx context.Context, tx *sql.Tx, id int, attempts *int, res *service.EnrichResult) (model.Person, error) {
+	old := model.Person{ID: id}
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE persons p
+		SET age=CASE WHEN 'age' = ANY(p.locked_fields) OR 'age' = ANY($4) THEN p.age ELSE $1 END,
+		    gender=CASE WHEN 'gender' = ANY(p.locked_fields) OR 'gender' = ANY($4) THEN p.gender ELSE $2 END,
+		    nationality=CASE WHEN 'nationality' = ANY(p.locked_fields) OR 'nationality' = ANY($4) THEN p.nationality ELSE $3 END,
+		    enrichment_missing=$4,
+		    enrichment_status=$5, enrichment_attempts=COALESCE($6, p.enrichment_attempts),
+		    enrichment_next_at=NULL, enrichment_error=NULL,
+		    age_sample_count=CASE WHEN 'age' = ANY(p.locked_fields) OR 'age' = ANY($4) THEN p.age_sample_count ELSE $7 END,
+		    gender_probability=CASE WHEN 'gender' = ANY(p.locked_fields) OR 'gender' = ANY($4) THEN p.gender_probability ELSE $8 END,
+		    gender_sample_count=CASE WHEN 'gender' = ANY(p.locked_fields) OR 'gender' = ANY($4) THEN p.gender_sample_count ELSE $9 END,
+		    nationality_candidates=CASE WHEN 'nationality' = ANY(p.locked_fields) OR 'nationality' = ANY($4) THEN p.nationality_candidates ELSE $10 END,
+		    nationality_sample_count=CASE WHEN 'nationality' = ANY(p.locked_fields) OR 'nationality' = ANY($4) THEN p.nationality_sample_count ELSE $15 END,
+		    enrichment_low_confidence=$11, enrichment_source=NULLIF($12, ''), enrichment_fallback_fields=$13,
+		    enriched_at=NOW()
+		FROM persons old
+		WHERE p.id=$14 AND old.id=p.id
+		RETURNING old.age, old.gender, old.nationality, p.locked_fields`,
+		res.Age, res.Gender, res.Nationality, pq.Array(nonNil(res.Missing)),
+		model.EnrichmentDone, attempts,
+		res.AgeCount, res.GenderProbability, res.GenderCount, res.NationalityCandidates,
+		pq.Array(nonNil(res.LowConfidence)), res.Source, pq.Array(nonNil(res.FallbackFields)), id,
+		res.NationalityCount,
+	).Scan(&old.Age, &old.Gender, &old.Nationality, pq.Array(&old.LockedFields)); err != nil {
+		return old, fmt.Errorf("save enrichment for id=%d: %w", id, err)
+	}
+	return old, nil
+}
+
+// fail записывает неудачную попытку: планирует повтор или переводит запись в статус dead.
+func (p *Pool) fail(ctx context.Context, tx *sql.Tx, id, attempts int, cause error) error {
+	if attempts >= p.Opts.MaxAttempts {
+		log.WithError(cause).Errorf("queue.Pool: person id=%d moved to dead-letter after %d attempts", id, attempts)
+		_, err := tx.ExecContext(ctx, `
+			UPDATE persons
+			SET enrichment_status=$1, enrichment_attempts=$2, enrichment_next_at=NULL, enrichment_error=$3
+			WHERE id=$4`,
+			model.EnrichmentDead, attempts, cause.Error(), id,
+		)
+		return err
+	}
+
+	delay := RetryDelay(p.Opts.RetryBackoff, attempts)
+	log.WithError(cause).Warnf("queue.Pool: person id=%d enrichment failed, retry in %s", id, delay)
+	_, err := tx.ExecContext(ctx, `
+		UPDATE persons
+		SET enrichment_attempts=$1, enrichment_next_at=$2, enrichment_error=$3
+		WHERE id=$4`,
+		attempts, time.Now().Add(delay), cause.Error(), id,
+	)
+	return err
+}
+
+// retry откладывает запись до сброса квоты, если причина ошибки — исчерпанная квота,
+// и записывает неудачную попытку в остальных случаях.
+func (p *Pool) retry(ctx context.Context, tx *sql.Tx, j job, cause error) error {
+	if resetAt, ok := service.QuotaResetAt(cause); ok {
+		return p.postpone(ctx, tx, j.id, resetAt, cause)
+	}
+	return p.fail(ctx, tx, j.id, j.attempts, cause)
+}
+
+// postpone откладывает обработку записи до until, не увеличивая счетчик попыток.
+func (p *Pool) postpone(ctx context.Context, tx *sql.Tx, id int, until time.Time, cause error) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE persons SET enrichment_next_at=$1, enrichment_error=$2 WHERE id=$3`,
+		until, cause.Error(), id,
+	)
+	return err
+}
+
+// RetryDelay возвращает задержку перед попыткой номер attempts+1: base, 2*base, 4*base, ...
+// Задержка ограничена сутками.
+func RetryDelay(base time.Duration, attempts int) time.Duration {
+	const maxDelay = 24 * time.Hour
+	d := base
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxDelay {
+			return maxDelay
+		}
+	}
+	return d
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
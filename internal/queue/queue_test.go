@@ -0,0 +1,45 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"effect/internal/model"
+	"effect/internal/service"
+)
+
+// TestRetryDelay проверяет удвоение задержки между попытками и ее ограничение сверху.
+func TestRetryDelay(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{20, 24 * time.Hour},
+	}
+	for _, c := range cases {
+		if got := RetryDelay(30*time.Second, c.attempts); got != c.want {
+			t.Errorf("RetryDelay(30s, %d) = %s, want %s", c.attempts, got, c.want)
+		}
+	}
+}
+
+// TestChangedFields проверяет, что в историю попадают только изменившиеся и незаблокированные атрибуты.
+func TestChangedFields(t *testing.T) {
+	age, gender, nationality := 30, "male", "RU"
+	newAge, newNationality := 31, "UA"
+	old := model.Person{Age: &age, Gender: &gender, Nationality: &nationality, LockedFields: []string{"nationality"}}
+	res := &service.EnrichResult{Age: &newAge, Gender: &gender, Nationality: &newNationality}
+
+	got := changedFields(old, res)
+	if len(got) != 1 || got[0] != service.FieldAge {
+		t.Errorf("expected [age], got %v", got)
+	}
+
+	res.Gender = nil
+	if got := changedFields(old, res); len(got) != 2 || got[1] != service.FieldGender {
+		t.Errorf("expected [age gender] when gender becomes null, got %v", got)
+	}
+}
@@ -0,0 +1,232 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"effect/internal/audit"
+	"effect/internal/model"
+	"effect/internal/service"
+)
+
+// RefreshOptions задает параметры повторного обогащения устаревших записей.
+type RefreshOptions struct {
+	// Interval — период запуска повторного обогащения.
+	Interval time.Duration
+	// MaxAge — запись считается устаревшей, если обогащена раньше, чем MaxAge назад.
+	MaxAge time.Duration
+	// BatchSize — сколько записей обогащается одним пакетным запросом.
+	BatchSize int
+	// MaxPerRun ограничивает число записей за один запуск, чтобы не выйти за квоты провайдеров.
+	MaxPerRun int
+	// BatchPause — пауза между пакетами внутри одного запуска.
+	BatchPause time.Duration
+	// Lease — срок аренды захваченных записей (см. Options.Lease).
+	Lease time.Duration
+}
+
+// Refresher периодически обогащает заново записи, обогащенные давно или с пропущенными полями,
+// и записывает изменившиеся атрибуты в историю (person_field_history).
+// Заблокированные вручную атрибуты не перезаписываются.
+type Refresher struct {
+	DB       *sql.DB
+	Enricher service.Enricher
+	Opts     RefreshOptions
+}
+
+// NewRefresher создает Refresher; нулевые значения опций заменяются значениями по умолчанию.
+func NewRefresher(db *sql.DB, enricher service.Enricher, opts RefreshOptions) *Refresher {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 30 * 24 * time.Hour
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > service.MaxBatchSize {
+		opts.BatchSize = service.MaxBatchSize
+	}
+	if opts.MaxPerRun <= 0 {
+		opts.MaxPerRun = 100
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = DefaultLease
+	}
+	return &Refresher{DB: db, Enricher: enricher, Opts: opts}
+}
+
+// Run запускает повторное обогащение каждые Interval и блокируется до отмены ctx.
+func (r *Refresher) Run(ctx context.Context) {
+	log.Infof("queue.Refresher: re-enriching records older than %s every %s, at most %d per run",
+		r.Opts.MaxAge, r.Opts.Interval, r.Opts.MaxPerRun)
+
+	ticker := time.NewTicker(r.Opts.Interval)
+	defer ticker.Stop()
+	for {
+		n, err := r.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.WithError(err).Error("queue.Refresher: run failed")
+		}
+		if n > 0 {
+			log.Infof("queue.Refresher: re-enriched %d persons", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("queue.Refresher: stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce обогащает заново до MaxPerRun устаревших записей пакетами по BatchSize
+// и возвращает число обработанных записей. Ошибка обогащения прерывает запуск:
+// оставшиеся записи будут обработаны в следующий раз.
+func (r *Refresher) RunOnce(ctx context.Context) (int, error) {
+	started := time.Now()
+	total := 0
+	for total < r.Opts.MaxPerRun {
+		if total > 0 && r.Opts.BatchPause > 0 {
+			select {
+			case <-ctx.Done():
+				return total, ctx.Err()
+			case <-time.After(r.Opts.BatchPause):
+			}
+		}
+
+		n, err := r.refreshBatch(ctx, started, min(r.Opts.BatchSize, r.Opts.MaxPerRun-total))
+		total += n
+		if err != nil || n == 0 {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// refreshBatch захватывает в аренду до limit устаревших записей и обогащает их заново.
+// Записи с пропущенными полями берутся, только если они не обогащались после started,
+// чтобы в одном запуске одна запись не обрабатывалась повторно. Как и в Pool.ProcessBatch,
+// внешние API вызываются без открытой транзакции, а результат сохраняется, только если имя
+// записи за это время не изменилось.
+func (r *Refresher) refreshBatch(ctx context.Context, started time.Time, limit int) (int, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		UPDATE persons SET enrichment_next_at = NOW() + make_interval(secs => $5)
+		WHERE id IN (
+			SELECT id FROM persons
+			WHERE enrichment_status = $1
+			  AND (enrichment_next_at IS NULL OR enrichment_next_at <= NOW())
+			  AND (enriched_at IS NULL OR enriched_at < $2
+			       OR (enrichment_missing <> '{}' AND enriched_at < $3))
+			ORDER BY enriched_at NULLS FIRST, id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, name, COALESCE(country_hint, '')`,
+		model.EnrichmentDone, started.Add(-r.Opts.MaxAge), started, limit, r.Opts.Lease.Seconds(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("select stale persons: %w", err)
+	}
+	var jobs []job
+	for rows.Next() {
+		var j job
+		if err := rows.Scan(&j.id, &j.query.Name, &j.query.CountryID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan stale person: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("select stale persons: %w", err)
+	}
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].id < jobs[b].id })
+	queries := make([]service.Query, len(jobs))
+	for i, j := range jobs {
+		queries[i] = j.query
+	}
+
+	results, err := service.EnrichAll(ctx, r.Enricher, queries)
+	if err != nil {
+		release(r.DB, jobIDs(jobs))
+		return 0, fmt.Errorf("re-enrich batch of %d: %w", len(jobs), err)
+	}
+	for _, j := range jobs {
+		// ответ локального словаря означает, что API недоступны: он не должен заменять
+		// сохраненные предсказания, поэтому запуск прерывается до следующего раза
+		if res := results[j.query]; res != nil && res.Source == service.SourceFallback {
+			release(r.DB, jobIDs(jobs))
+			return 0, fmt.Errorf("re-enrich person id=%d: providers unavailable, offline dictionary used", j.id)
+		}
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, j := range jobs {
+		res := results[j.query]
+		if res == nil {
+			continue
+		}
+		ok, err := unchanged(ctx, tx, j.id, j.query, model.EnrichmentDone)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			log.Infof("queue.Refresher: person id=%d changed during re-enrichment, result discarded", j.id)
+			continue
+		}
+		old, err := storeResult(ctx, tx, j.id, nil, res)
+		if err != nil {
+			return 0, err
+		}
+
+		changed := changedFields(old, res)
+		if len(changed) == 0 {
+			continue
+		}
+		log.Infof("queue.Refresher: person id=%d changed %v", j.id, changed)
+
+		prov := make(model.Provenance, len(changed))
+		for _, field := range changed {
+			if fp, ok := res.Provenance[field]; ok {
+				prov[field] = fp
+			}
+		}
+		person := model.Person{ID: j.id, Age: res.Age, Gender: res.Gender, Nationality: res.Nationality}
+		if err := audit.Record(ctx, tx, person, prov); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(jobs), tx.Commit()
+}
+
+// changedFields возвращает незаблокированные и полученные атрибуты, значения которых в res отличаются от old.
+func changedFields(old model.Person, res *service.EnrichResult) []string {
+	before := audit.FieldValues(old)
+	after := audit.FieldValues(model.Person{Age: res.Age, Gender: res.Gender, Nationality: res.Nationality})
+
+	var changed []string
+	for _, field := range []string{service.FieldAge, service.FieldGender, service.FieldNationality} {
+		if slices.Contains(old.LockedFields, field) || slices.Contains(res.Missing, field) {
+			continue
+		}
+		b, a := before[field], after[field]
+		if (b == nil) != (a == nil) || (b != nil && *b != *a) {
+			changed = append(changed, field)
+		}
+	}
+	return changed
+}
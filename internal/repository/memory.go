@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"effect/internal/audit"
+	"effect/internal/model"
+)
+
+// historyEntry — запись истории атрибута в памяти (аналог строки person_field_history).
+type historyEntry struct {
+	field string
+	prov  model.FieldProvenance
+}
+
+// MemoryRepository хранит записи в памяти процесса. Используется в тестах и в демонстрационном
+// режиме без PostgreSQL; фильтрация, пагинация и порядок совпадают с PostgresRepository.
+// Данные теряются при перезапуске.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	persons map[int]model.Person
+	history map[int][]historyEntry
+	nextID  int
+
+	// rowLocks сериализуют Update одной записи, не блокируя все хранилище на время fn.
+	// Записи распределяются по фиксированному набору мьютексов, чтобы их число не росло с числом записей.
+	rowLocks [rowLockStripes]sync.Mutex
+}
+
+// rowLockStripes — число мьютексов, по которым распределяются записи.
+const rowLockStripes = 64
+
+var _ PersonRepository = (*MemoryRepository)(nil)
+
+// NewMemoryRepository создает пустое хранилище.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		persons: map[int]model.Person{},
+		history: map[int][]historyEntry{},
+	}
+}
+
+func (r *MemoryRepository) Create(ctx context.Context, p *model.Person, prov model.Provenance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	p.ID, p.CreatedAt = r.nextID, time.Now().UTC()
+	if p.EnrichmentStatus == "" {
+		// как DEFAULT столбца enrichment_status
+		p.EnrichmentStatus = model.EnrichmentDone
+	}
+	r.persons[p.ID] = clonePerson(*p)
+	r.record(*p, prov)
+	return nil
+}
+
+func (r *MemoryRepository) Get(ctx context.Context, id int) (model.Person, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.persons[id]
+	if !ok {
+		return model.Person{}, ErrNotFound
+	}
+	return clonePerson(p), nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, f ListFilter) ([]model.Person, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []model.Person
+	for _, p := range r.persons {
+		if containsFold(p.Name, f.Name) && containsFold(p.Surname, f.Surname) {
+			matched = append(matched, p)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	if f.Offset >= len(matched) {
+		return nil, nil
+	}
+	matched = matched[max(f.Offset, 0):]
+	if f.Limit > 0 && f.Limit < len(matched) {
+		matched = matched[:f.Limit]
+	}
+
+	result := make([]model.Person, len(matched))
+	for i, p := range matched {
+		result[i] = clonePerson(p)
+	}
+	return result, nil
+}
+
+func (r *MemoryRepository) Update(ctx context.Context, id int, fn UpdateFunc) error {
+	lock := r.rowLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	p, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	change, err := fn(&p, r)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.persons[id]; !ok {
+		// запись удалили, пока выполнялась fn
+		return ErrNotFound
+	}
+	p.ID = id
+	r.persons[id] = clonePerson(p)
+	r.record(p, change.Provenance)
+	return nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.persons[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.persons, id)
+	delete(r.history, id)
+	return nil
+}
+
+// Provenance возвращает последнюю запись истории по каждому атрибуту записи.
+func (r *MemoryRepository) Provenance(ctx context.Context, id int) (model.Provenance, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	prov := model.Provenance{}
+	for _, e := range r.history[id] {
+		prov[e.field] = e.prov
+	}
+	return prov, nil
+}
+
+func (r *MemoryRepository) History(ctx context.Context, id int) ([]model.HistoryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	history := make([]model.HistoryEntry, len(r.history[id]))
+	for i, e := range r.history[id] {
+		history[i] = model.HistoryEntry{Field: e.field, FieldProvenance: e.prov}
+	}
+	return history, nil
+}
+
+// EnrichmentState возвращает статус обогащения; очереди в памяти нет, поэтому попытки не учитываются.
+func (r *MemoryRepository) EnrichmentState(ctx context.Context, id int) (model.EnrichmentState, error) {
+	p, err := r.Get(ctx, id)
+	if err != nil {
+		return model.EnrichmentState{ID: id}, err
+	}
+	return model.EnrichmentState{ID: id, Status: p.EnrichmentStatus}, nil
+}
+
+func (r *MemoryRepository) LatestInferred(ctx context.Context, personID int, field string) (model.FieldProvenance, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := r.history[personID]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; e.field == field && e.prov.Inferred {
+			return e.prov, true, nil
+		}
+	}
+	return model.FieldProvenance{}, false, nil
+}
+
+// record добавляет в историю текущие значения атрибутов p так же, как audit.Record; вызывается под r.mu.
+func (r *MemoryRepository) record(p model.Person, prov model.Provenance) {
+	values := audit.FieldValues(p)
+	fields := make([]string, 0, len(prov))
+	for field := range prov {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		value, ok := values[field]
+		if !ok {
+			continue
+		}
+		fp := prov[field]
+		fp.Value = clonePtr(value)
+		fp.Raw = slices.Clone(fp.Raw)
+		r.history[p.ID] = append(r.history[p.ID], historyEntry{field: field, prov: fp})
+	}
+}
+
+// rowLock возвращает мьютекс записи id.
+func (r *MemoryRepository) rowLock(id int) *sync.Mutex {
+	return &r.rowLocks[uint(id)%rowLockStripes]
+}
+
+// containsFold сообщает, содержит ли s подстроку substr без учета регистра (аналог ILIKE '%substr%').
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// clonePerson возвращает копию p, не разделяющую с ней срезы и указатели.
+func clonePerson(p model.Person) model.Person {
+	p.Patronymic, p.Age, p.Gender, p.Nationality = clonePtr(p.Patronymic), clonePtr(p.Age), clonePtr(p.Gender), clonePtr(p.Nationality)
+	p.CountryHint, p.EnrichmentSource, p.EnrichedAt = clonePtr(p.CountryHint), clonePtr(p.EnrichmentSource), clonePtr(p.EnrichedAt)
+	p.AgeSampleCount, p.GenderProbability, p.GenderSampleCount = clonePtr(p.AgeSampleCount), clonePtr(p.GenderProbability), clonePtr(p.GenderSampleCount)
+	p.MissingFields, p.LowConfidenceFields = slices.Clone(p.MissingFields), slices.Clone(p.LowConfidenceFields)
+	p.FallbackFields, p.LockedFields = slices.Clone(p.FallbackFields), slices.Clone(p.LockedFields)
+	p.NationalityCandidates, p.NationalitySampleCount = slices.Clone(p.NationalityCandidates), clonePtr(p.NationalitySampleCount)
+	p.Provenance = nil
+	return p
+}
+
+func clonePtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"effect/internal/model"
+)
+
+// TestMemoryRepository_ListFilterAndPagination проверяет фильтр без учета регистра, порядок по ID и пагинацию.
+func TestMemoryRepository_ListFilterAndPagination(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+	for _, name := range []string{"Ivan", "Anna", "Ivanna", "Boris", "Divan"} {
+		if err := r.Create(ctx, &model.Person{Name: name, Surname: "Petrov"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := r.List(ctx, ListFilter{Name: "IVAN", Surname: "pet", Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "Ivanna" || got[1].Name != "Divan" {
+		t.Errorf("unexpected page %+v", got)
+	}
+
+	if got, _ := r.List(ctx, ListFilter{Offset: 10}); len(got) != 0 {
+		t.Errorf("expected empty page past the end, got %d", len(got))
+	}
+	if got, _ := r.List(ctx, ListFilter{Surname: "ivanov"}); len(got) != 0 {
+		t.Errorf("expected no matches, got %d", len(got))
+	}
+}
+
+// TestMemoryRepository_ReturnsCopies проверяет, что изменение возвращенной записи не меняет хранимую.
+func TestMemoryRepository_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+	age := 30
+	p := model.Person{Name: "Ivan", Age: &age, LockedFields: []string{"age"}}
+	if err := r.Create(ctx, &p, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := r.Get(ctx, p.ID)
+	*got.Age = 99
+	got.LockedFields[0] = "gender"
+	age = 77
+
+	stored, _ := r.Get(ctx, p.ID)
+	if *stored.Age != 30 || stored.LockedFields[0] != "age" {
+		t.Errorf("stored person was modified: age=%d locked=%v", *stored.Age, stored.LockedFields)
+	}
+	if stored.EnrichmentStatus != model.EnrichmentDone {
+		t.Errorf("expected default status done, got %q", stored.EnrichmentStatus)
+	}
+}
+
+// TestMemoryRepository_UpdateHistory проверяет историю атрибутов и отмену обновления при ошибке.
+func TestMemoryRepository_UpdateHistory(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+	gender := "male"
+	p := model.Person{Name: "Ivan", Gender: &gender}
+	if err := r.Create(ctx, &p, model.Provenance{"gender": {Source: model.SourceAPI, Inferred: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	manual := "female"
+	err := r.Update(ctx, p.ID, func(p *model.Person, h History) (Change, error) {
+		p.Gender = &manual
+		return Change{Provenance: model.Provenance{"gender": {Source: model.SourceManual}}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prov, _ := r.Provenance(ctx, p.ID)
+	if prov["gender"].Source != model.SourceManual || *prov["gender"].Value != "female" {
+		t.Errorf("expected manual gender as current provenance, got %+v", prov["gender"])
+	}
+	fp, found, _ := r.LatestInferred(ctx, p.ID, "gender")
+	if !found || *fp.Value != "male" {
+		t.Errorf("expected inferred gender male, got %+v found=%v", fp, found)
+	}
+
+	boom := errors.New("boom")
+	err = r.Update(ctx, p.ID, func(p *model.Person, h History) (Change, error) {
+		p.Name = "Anna"
+		return Change{}, boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected fn error, got %v", err)
+	}
+	if got, _ := r.Get(ctx, p.ID); got.Name != "Ivan" {
+		t.Errorf("failed update must not be stored, got name %q", got.Name)
+	}
+
+	if err := r.Update(ctx, 42, func(*model.Person, History) (Change, error) { return Change{}, nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := r.Delete(ctx, 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestMemoryRepository_Concurrent проверяет, что параллельные создания и обновления не теряются.
+func TestMemoryRepository_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+	age := 0
+	p := model.Person{Name: "Counter", Age: &age}
+	if err := r.Create(ctx, &p, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r.Create(ctx, &model.Person{Name: "Ivan"}, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			r.Update(ctx, p.ID, func(p *model.Person, h History) (Change, error) {
+				*p.Age++
+				return Change{}, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if got, _ := r.List(ctx, ListFilter{}); len(got) != 51 {
+		t.Errorf("expected 51 persons, got %d", len(got))
+	}
+	if got, _ := r.Get(ctx, p.ID); *got.Age != 50 {
+		t.Errorf("expected 50 serialized updates, got %d", *got.Age)
+	}
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"effect/internal/audit"
+	"effect/internal/model"
+)
+
+// personColumns — столбцы persons в порядке, ожидаемом scanPerson.
+const personColumns = `id, name, surname, patronymic, age, gender, nationality, country_hint, created_at,
+	enrichment_missing, enrichment_status,
+	age_sample_count, gender_probability, gender_sample_count, nationality_candidates, nationality_sample_count,
+	enrichment_low_confidence, enrichment_source, enrichment_fallback_fields, locked_fields, enriched_at`
+
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPerson читает строку, выбранную по personColumns.
+func scanPerson(row rowScanner) (model.Person, error) {
+	var p model.Person
+	err := row.Scan(&p.ID, &p.Name, &p.Surname, &p.Patronymic,
+		&p.Age, &p.Gender, &p.Nationality, &p.CountryHint, &p.CreatedAt,
+		pq.Array(&p.MissingFields), &p.EnrichmentStatus,
+		&p.AgeSampleCount, &p.GenderProbability, &p.GenderSampleCount, &p.NationalityCandidates, &p.NationalitySampleCount,
+		pq.Array(&p.LowConfidenceFields), &p.EnrichmentSource, pq.Array(&p.FallbackFields),
+		pq.Array(&p.LockedFields), &p.EnrichedAt)
+	return p, err
+}
+
+// PostgresRepository хранит записи в таблице persons, а историю атрибутов — в person_field_history.
+type PostgresRepository struct {
+	DB *sql.DB
+}
+
+var _ PersonRepository = (*PostgresRepository)(nil)
+
+// NewPostgresRepository создает репозиторий поверх соединения db.
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{DB: db}
+}
+
+func (r *PostgresRepository) Create(ctx context.Context, p *model.Person, prov model.Provenance) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO persons (name, surname, patronymic, age, gender, nationality, enrichment_missing, enrichment_status,
+		                     age_sample_count, gender_probability, gender_sample_count, nationality_candidates,
+		                     enrichment_low_confidence, country_hint, enrichment_source, enrichment_fallback_fields,
+		                     enriched_at, locked_fields, nationality_sample_count)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19) RETURNING id, created_at`,
+		p.Name, p.Surname, p.Patronymic, p.Age, p.Gender, p.Nationality, pq.Array(nonNil(p.MissingFields)),
+		p.EnrichmentStatus, p.AgeSampleCount, p.GenderProbability, p.GenderSampleCount, p.NationalityCandidates,
+		pq.Array(nonNil(p.LowConfidenceFields)), p.CountryHint, p.EnrichmentSource, pq.Array(nonNil(p.FallbackFields)),
+		p.EnrichedAt, pq.Array(nonNil(p.LockedFields)), p.NationalitySampleCount,
+	).Scan(&p.ID, &p.CreatedAt); err != nil {
+		return fmt.Errorf("insert person: %w", err)
+	}
+	if err := audit.Record(ctx, tx, *p, prov); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) Get(ctx context.Context, id int) (model.Person, error) {
+	p, err := scanPerson(r.DB.QueryRowContext(ctx, `SELECT `+personColumns+` FROM persons WHERE id=$1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrNotFound
+	}
+	return p, err
+}
+
+func (r *PostgresRepository) List(ctx context.Context, f ListFilter) ([]model.Person, error) {
+	var where []string
+	var args []interface{}
+	if f.Name != "" {
+		args = append(args, "%"+f.Name+"%")
+		where = append(where, fmt.Sprintf("name ILIKE $%d", len(args)))
+	}
+	if f.Surname != "" {
+		args = append(args, "%"+f.Surname+"%")
+		where = append(where, fmt.Sprintf("surname ILIKE $%d", len(args)))
+	}
+
+	query := `SELECT ` + personColumns + ` FROM persons`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+	query += fmt.Sprintf(" OFFSET %d", max(f.Offset, 0))
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []model.Person
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+func (r *PostgresRepository) Update(ctx context.Context, id int, fn UpdateFunc) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	p, err := scanPerson(tx.QueryRowContext(ctx, `SELECT `+personColumns+` FROM persons WHERE id=$1 FOR UPDATE`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	change, err := fn(&p, txHistory{tx})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE persons SET name=$1, surname=$2, patronymic=$3, country_hint=$4,
+		                    age=$5, gender=$6, nationality=$7, locked_fields=$8,
+		                    enrichment_missing=$9, enrichment_status=$10,
+		                    age_sample_count=$11, gender_probability=$12, gender_sample_count=$13,
+		                    nationality_candidates=$14, enrichment_low_confidence=$15,
+		                    enrichment_source=$16, enrichment_fallback_fields=$17, enriched_at=$20,
+		                    nationality_sample_count=$21,
+		                    enrichment_attempts=CASE WHEN $18 THEN 0 ELSE enrichment_attempts END,
+		                    enrichment_next_at=CASE WHEN $18 THEN NULL ELSE enrichment_next_at END,
+		                    enrichment_error=CASE WHEN $18 THEN NULL ELSE enrichment_error END
+		WHERE id=$19`,
+		p.Name, p.Surname, p.Patronymic, p.CountryHint,
+		p.Age, p.Gender, p.Nationality, pq.Array(nonNil(p.LockedFields)),
+		pq.Array(nonNil(p.MissingFields)), p.EnrichmentStatus,
+		p.AgeSampleCount, p.GenderProbability, p.GenderSampleCount,
+		p.NationalityCandidates, pq.Array(nonNil(p.LowConfidenceFields)),
+		p.EnrichmentSource, pq.Array(nonNil(p.FallbackFields)),
+		change.Requeue, id, p.EnrichedAt, p.NationalitySampleCount,
+	); err != nil {
+		return fmt.Errorf("update person id=%d: %w", id, err)
+	}
+	if err := audit.Record(ctx, tx, p, change.Provenance); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, id int) error {
+	res, err := r.DB.ExecContext(ctx, "DELETE FROM persons WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Provenance(ctx context.Context, id int) (model.Provenance, error) {
+	return audit.Current(ctx, r.DB, id)
+}
+
+func (r *PostgresRepository) History(ctx context.Context, id int) ([]model.HistoryEntry, error) {
+	return audit.History(ctx, r.DB, id)
+}
+
+func (r *PostgresRepository) EnrichmentState(ctx context.Context, id int) (model.EnrichmentState, error) {
+	st := model.EnrichmentState{ID: id}
+	err := r.DB.QueryRowContext(ctx,
+		`SELECT enrichment_status, enrichment_attempts,
+		        CASE WHEN enrichment_status = $2 THEN enrichment_next_at END, enrichment_error
+		FROM persons WHERE id=$1`,
+		id, model.EnrichmentPending,
+	).Scan(&st.Status, &st.Attempts, &st.NextAttemptAt, &st.LastError)
+	if errors.Is(err, sql.ErrNoRows) {
+		return st, ErrNotFound
+	}
+	return st, err
+}
+
+// txHistory читает историю атрибутов в транзакции Update.
+type txHistory struct {
+	tx *sql.Tx
+}
+
+func (h txHistory) LatestInferred(ctx context.Context, personID int, field string) (model.FieldProvenance, bool, error) {
+	return audit.LatestInferred(ctx, h.tx, personID, field)
+}
+
+// nonNil возвращает пустой срез вместо nil, чтобы в столбец TEXT[] NOT NULL записался '{}'.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
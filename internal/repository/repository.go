@@ -0,0 +1,61 @@
+// Package repository отделяет хранение записей persons от HTTP-обработчиков.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"effect/internal/model"
+)
+
+// ErrNotFound возвращается, если записи с указанным ID нет.
+var ErrNotFound = errors.New("person not found")
+
+// ListFilter — параметры выборки списка записей.
+type ListFilter struct {
+	// Name и Surname — подстроки имени и фамилии без учета регистра; пустое значение не фильтрует.
+	Name    string
+	Surname string
+	// Limit <= 0 означает выборку без ограничения.
+	Limit  int
+	Offset int
+}
+
+// Change описывает изменение записи, выполненное функцией UpdateFunc.
+type Change struct {
+	// Provenance — происхождение измененных атрибутов; оно записывается в историю.
+	Provenance model.Provenance
+	// Requeue сбрасывает счетчики асинхронного обогащения, чтобы запись обогащалась заново с первой попытки.
+	Requeue bool
+}
+
+// History дает доступ к истории атрибутов записи внутри Update.
+type History interface {
+	// LatestInferred возвращает последнее предсказанное (не заданное вручную) значение атрибута field.
+	LatestInferred(ctx context.Context, personID int, field string) (model.FieldProvenance, bool, error)
+}
+
+// UpdateFunc изменяет текущую запись p; ошибка отменяет обновление и возвращается из Update.
+type UpdateFunc func(p *model.Person, history History) (Change, error)
+
+// PersonRepository хранит записи persons и историю их атрибутов.
+type PersonRepository interface {
+	// Create сохраняет p, заполняет ID и CreatedAt и записывает в историю атрибуты из prov.
+	Create(ctx context.Context, p *model.Person, prov model.Provenance) error
+	// Get возвращает запись по ID или ErrNotFound.
+	Get(ctx context.Context, id int) (model.Person, error)
+	// List возвращает записи, подходящие под фильтр, упорядоченные по ID.
+	List(ctx context.Context, f ListFilter) ([]model.Person, error)
+	// Update читает запись под блокировкой, применяет к ней fn и сохраняет результат.
+	// Возвращает ErrNotFound, если записи нет.
+	Update(ctx context.Context, id int, fn UpdateFunc) error
+	// Delete удаляет запись или возвращает ErrNotFound.
+	Delete(ctx context.Context, id int) error
+
+	// Provenance возвращает текущее происхождение атрибутов записи.
+	Provenance(ctx context.Context, id int) (model.Provenance, error)
+	// History возвращает все изменения атрибутов записи в порядке их записи.
+	History(ctx context.Context, id int) ([]model.HistoryEntry, error)
+	// EnrichmentState возвращает состояние асинхронного обогащения записи или ErrNotFound.
+	EnrichmentState(ctx context.Context, id int) (model.EnrichmentState, error)
+}
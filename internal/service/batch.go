@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxBatchSize — максимальное число имен в одном пакетном запросе к провайдеру.
+	MaxBatchSize = 10
+	// DefaultBatchConcurrency — число одновременно выполняемых пакетных запросов по умолчанию.
+	DefaultBatchConcurrency = 4
+)
+
+// BatchEnricher обогащает сразу несколько запросов.
+// Результат содержит запись для каждого переданного запроса в исходном виде.
+type BatchEnricher interface {
+	EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error)
+}
+
+// BatchProvider — провайдер, поддерживающий пакетный запрос вида name[]=...
+// В EnrichBatch передается не более MaxBatchSize запросов с одинаковым CountryID.
+type BatchProvider interface {
+	Provider
+	BatchEnricher
+}
+
+// PartialError — ошибка обогащения части запросов пакета. Она возвращается вместе с результатами
+// остальных запросов; для запросов из Failed результатов нет.
+type PartialError struct {
+	Err    error
+	Failed map[Query]bool
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d names of batch not enriched: %v", len(e.Failed), e.Err)
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// AsPartial сообщает, является ли err ошибкой обогащения только части пакета.
+func AsPartial(err error) (*PartialError, bool) {
+	var pe *PartialError
+	if errors.As(err, &pe) {
+		return pe, true
+	}
+	return nil, false
+}
+
+// remap переносит неудачные запросы inner на соответствующие им исходные запросы outer.
+// Для nil возвращает nil.
+func (e *PartialError) remap(outer, inner []Query) error {
+	if e == nil {
+		return nil
+	}
+	failed := make(map[Query]bool, len(e.Failed))
+	for i, q := range outer {
+		if e.Failed[inner[i]] {
+			failed[q] = true
+		}
+	}
+	return &PartialError{Err: e.Err, Failed: failed}
+}
+
+// EnrichAll обогащает список запросов пакетно, если e поддерживает BatchEnricher,
+// и по одному запросу в противном случае.
+func EnrichAll(ctx context.Context, e Enricher, queries []Query) (map[Query]*EnrichResult, error) {
+	if be, ok := e.(BatchEnricher); ok {
+		return be.EnrichBatch(ctx, queries)
+	}
+	return enrichEach(ctx, e, queries)
+}
+
+// enrichEach последовательно обогащает запросы по одному.
+func enrichEach(ctx context.Context, e Enricher, queries []Query) (map[Query]*EnrichResult, error) {
+	out := make(map[Query]*EnrichResult, len(queries))
+	for _, q := range queries {
+		res, err := e.Enrich(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		out[q] = res
+	}
+	return out, nil
+}
+
+// providerBatch вызывает пакетный API провайдера, если он есть.
+func providerBatch(ctx context.Context, p Provider, queries []Query) (map[Query]*EnrichResult, error) {
+	if bp, ok := p.(BatchProvider); ok {
+		return bp.EnrichBatch(ctx, queries)
+	}
+	return enrichEach(ctx, p, queries)
+}
+
+// batchChunks нормализует запросы, убирает повторы и разбивает их на пакеты
+// не более MaxBatchSize с одинаковым CountryID внутри пакета.
+func batchChunks(queries []Query) (unique []Query, chunks [][]Query) {
+	seen := make(map[Query]bool, len(queries))
+	byCountry := make(map[string][]Query)
+	var countries []string
+	for _, q := range queries {
+		n := q.normalized()
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		unique = append(unique, n)
+		if _, ok := byCountry[n.CountryID]; !ok {
+			countries = append(countries, n.CountryID)
+		}
+		byCountry[n.CountryID] = append(byCountry[n.CountryID], n)
+	}
+
+	for _, country := range countries {
+		group := byCountry[country]
+		for start := 0; start < len(group); start += MaxBatchSize {
+			chunks = append(chunks, group[start:min(start+MaxBatchSize, len(group))])
+		}
+	}
+	return unique, chunks
+}
+
+// EnrichBatch обогащает список запросов: дедуплицирует их, разбивает на пакеты по MaxBatchSize
+// и выполняет пакеты параллельно, не более BatchConcurrency одновременно.
+// Обработка ошибок определяется Policy так же, как в Enrich.
+func (c *CompositeEnricher) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	unique, chunks := batchChunks(queries)
+	results := make(map[Query]*EnrichResult, len(unique))
+	for _, q := range unique {
+		results[q] = &EnrichResult{}
+	}
+
+	if c.Policy == PolicySkip {
+		for _, res := range results {
+			for _, p := range c.Providers {
+				res.Missing = append(res.Missing, p.Field())
+			}
+		}
+		return mapBack(queries, results), nil
+	}
+
+	concurrency := c.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+	log.Debugf("service.EnrichBatch: enriching %d unique names of %d in %d batches", len(unique), len(queries), len(chunks))
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		err error
+		sem = make(chan struct{}, concurrency)
+	)
+	for _, chunk := range chunks {
+		for _, p := range c.Providers {
+			wg.Add(1)
+			go func(p Provider, chunk []Query) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				r, e := providerBatch(ctx, p, chunk)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if e != nil {
+					err = e
+					for _, q := range chunk {
+						results[q].Missing = append(results[q].Missing, p.Field())
+					}
+					log.WithError(e).Errorf("service.EnrichBatch: %s batch of %d failed", p.Name(), len(chunk))
+					return
+				}
+				for _, q := range chunk {
+					mergeResult(results[q], r[q])
+				}
+			}(p, chunk)
+		}
+	}
+	wg.Wait()
+
+	if err != nil && c.Policy != PolicyBestEffort {
+		return nil, err
+	}
+	for _, res := range results {
+		sort.Strings(res.Missing)
+	}
+	return mapBack(queries, results), nil
+}
+
+// mapBack сопоставляет результаты по нормализованным запросам с исходными запросами.
+func mapBack(queries []Query, results map[Query]*EnrichResult) map[Query]*EnrichResult {
+	out := make(map[Query]*EnrichResult, len(queries))
+	for _, q := range queries {
+		out[q] = results[q.normalized()]
+	}
+	return out
+}
+
+// EnrichBatch берет найденные в кэше запросы из кэша, а остальные обогащает пакетно через Next.
+func (c *CachedEnricher) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	out := make(map[Query]*EnrichResult, len(queries))
+	var misses []Query
+	for _, q := range queries {
+		key := q.Key()
+		res, found, err := c.Cache.Get(ctx, key)
+		if err != nil {
+			log.WithError(err).Warnf("service.CachedEnricher: cache get failed for key=%s", key)
+		}
+		if found {
+			c.hits.Add(1)
+			out[q] = fromCache(res)
+			continue
+		}
+		c.misses.Add(1)
+		misses = append(misses, q)
+	}
+	if len(misses) == 0 {
+		return out, nil
+	}
+
+	fetched, err := EnrichAll(ctx, c.Next, misses)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range misses {
+		res := fetched[q]
+		out[q] = res
+		if res == nil || len(res.Missing) > 0 {
+			continue
+		}
+		if err := c.Cache.Set(ctx, q.Key(), res); err != nil {
+			log.WithError(err).Warnf("service.CachedEnricher: cache set failed for key=%s", q.Key())
+		}
+	}
+	return out, nil
+}
+
+// EnrichBatch применяет пороги достоверности к каждому результату пакетного обогащения.
+// PartialError возвращается вместе с результатами остальных запросов.
+func (e *ThresholdEnricher) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	results, err := EnrichAll(ctx, e.Next, queries)
+	if _, partial := AsPartial(err); err != nil && !partial {
+		return nil, err
+	}
+	for q, res := range results {
+		results[q] = e.Thresholds.Apply(res)
+	}
+	return results, err
+}
+
+// EnrichBatch выполняет пакетный запрос через выключатель провайдера.
+func (p *BreakerProvider) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	if err := p.Breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Name(), err)
+	}
+
+	res, err := providerBatch(ctx, p.Provider, queries)
+	p.record(err)
+	return res, err
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestEnrichBatch_ShortResponse проверяет, что ответ провайдера с меньшим числом элементов, чем имен,
+// считается ошибкой пакета: при best-effort поле попадает в Missing, и результаты не кэшируются.
+func TestEnrichBatch_ShortResponse(t *testing.T) {
+	agify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"anna","age":30,"count":100}]`))
+	}))
+	defer agify.Close()
+
+	composite := NewCompositeEnricher(&AgifyProvider{BaseURL: agify.URL, Client: instantClient(nil)})
+	composite.Policy = PolicyBestEffort
+	cache := NewMemoryCache(10, 0)
+	e := NewCachedEnricher(composite, cache)
+
+	queries := []Query{{Name: "anna"}, {Name: "ivan"}}
+	results, err := e.EnrichBatch(context.Background(), queries)
+	if err != nil {
+		t.Fatalf("EnrichBatch returned error: %v", err)
+	}
+	for _, q := range queries {
+		if res := results[q]; res == nil || res.Age != nil || strings.Join(res.Missing, ",") != FieldAge {
+			t.Errorf("expected age missing for %+v, got %+v", q, res)
+		}
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected incomplete results not cached, got %d entries", cache.Len())
+	}
+}
+
+// TestCompositeEnrichBatch проверяет, что имена дедуплицируются, группируются по MaxBatchSize
+// и стране, а результаты сопоставляются с исходными запросами.
+func TestCompositeEnrichBatch(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = map[string]int{}
+	)
+	agify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names := r.URL.Query()["name[]"]
+		if len(names) > MaxBatchSize {
+			t.Errorf("batch too large: %d names", len(names))
+		}
+		mu.Lock()
+		requests[r.URL.Query().Get("country_id")]++
+		mu.Unlock()
+
+		resp := make([]map[string]interface{}, len(names))
+		for i, name := range names {
+			resp[i] = map[string]interface{}{"name": name, "age": len(name), "count": 100}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer agify.Close()
+
+	var queries []Query
+	for i := 0; i < 12; i++ {
+		queries = append(queries, Query{Name: fmt.Sprintf("name%02d", i)})
+	}
+	// повторы в другом регистре не должны порождать лишних запросов
+	queries = append(queries, Query{Name: "NAME00"}, Query{Name: " name01 "})
+	// запросы с другой страной идут отдельным пакетом
+	queries = append(queries, Query{Name: "dmitriy", CountryID: "ru"})
+
+	e := NewCompositeEnricher(&AgifyProvider{BaseURL: agify.URL, Client: instantClient(nil)})
+	results, err := e.EnrichBatch(context.Background(), queries)
+	if err != nil {
+		t.Fatalf("EnrichBatch returned error: %v", err)
+	}
+
+	if requests[""] != 2 || requests["RU"] != 1 {
+		t.Errorf("expected 2 global and 1 RU batch requests, got %v", requests)
+	}
+	if len(results) != len(queries) {
+		t.Fatalf("expected %d results, got %d", len(queries), len(results))
+	}
+	for _, q := range queries {
+		res := results[q]
+		if res == nil || res.Age == nil || *res.Age != len(strings.TrimSpace(q.Name)) {
+			t.Errorf("unexpected result for %+v: %+v", q, res)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrCircuitOpen возвращается, когда автомат провайдера разомкнут и запрос к нему не выполняется.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState — состояние автоматического выключателя.
+type BreakerState int
+
+const (
+	// StateClosed — запросы проходят, ошибки считаются.
+	StateClosed BreakerState = iota
+	// StateOpen — запросы отклоняются до окончания периода охлаждения.
+	StateOpen
+	// StateHalfOpen — пропускается один пробный запрос.
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker — автоматический выключатель: после FailureThreshold ошибок подряд
+// размыкается на Cooldown, затем пропускает один пробный запрос.
+type Breaker struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	// now используется для подмены времени в тестах.
+	now func() time.Time
+}
+
+// BreakerStatus — снимок состояния выключателя для служебного эндпоинта.
+type BreakerStatus struct {
+	Provider string     `json:"provider"`
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+// NewBreaker создает выключатель с порогом ошибок threshold и периодом охлаждения cooldown.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &Breaker{FailureThreshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+// Allow сообщает, можно ли выполнить запрос. В полуоткрытом состоянии
+// разрешается только один одновременный пробный запрос.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.Cooldown {
+		b.state = StateHalfOpen
+		b.probing = false
+	}
+
+	switch b.state {
+	case StateOpen:
+		return ErrCircuitOpen
+	case StateHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record учитывает результат запроса, разрешенного через Allow.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.state, b.failures, b.probing = StateClosed, 0, false
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.FailureThreshold {
+		b.state, b.openedAt, b.probing = StateOpen, b.now(), false
+	}
+}
+
+// release снимает отметку пробного запроса, не меняя состояния.
+func (b *Breaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// State возвращает текущее состояние выключателя.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.Cooldown {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) status(provider string) BreakerStatus {
+	st := BreakerStatus{Provider: provider, State: b.State().String()}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st.Failures = b.failures
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		st.OpenedAt = &openedAt
+	}
+	return st
+}
+
+// BreakerProvider оборачивает Provider автоматическим выключателем.
+type BreakerProvider struct {
+	Provider
+	Breaker *Breaker
+}
+
+// NewBreakerProvider оборачивает провайдера p выключателем b.
+func NewBreakerProvider(p Provider, b *Breaker) *BreakerProvider {
+	return &BreakerProvider{Provider: p, Breaker: b}
+}
+
+func (p *BreakerProvider) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	if err := p.Breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Name(), err)
+	}
+
+	res, err := p.Provider.Enrich(ctx, q)
+	p.record(err)
+	return res, err
+}
+
+// record учитывает результат запроса и логирует смену состояния выключателя.
+func (p *BreakerProvider) record(err error) {
+	// отмена запроса клиентом и исчерпанная квота не говорят о состоянии провайдера
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrQuotaExhausted) {
+		p.Breaker.release()
+		return
+	}
+
+	before := p.Breaker.State()
+	p.Breaker.Record(err)
+	if after := p.Breaker.State(); after != before {
+		log.Warnf("service.BreakerProvider: %s circuit %s -> %s", p.Name(), before, after)
+	}
+}
+
+// Status возвращает снимок состояния выключателя провайдера.
+func (p *BreakerProvider) Status() BreakerStatus {
+	return p.Breaker.status(p.Name())
+}
+
+// BreakerStatuses возвращает состояния выключателей всех провайдеров, обернутых BreakerProvider.
+func (c *CompositeEnricher) BreakerStatuses() []BreakerStatus {
+	statuses := []BreakerStatus{}
+	for _, p := range c.Providers {
+		if bp, ok := p.(*BreakerProvider); ok {
+			statuses = append(statuses, bp.Status())
+		}
+	}
+	return statuses
+}
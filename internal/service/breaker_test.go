@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestBreaker_OpensAndRecovers проверяет полный цикл closed -> open -> half-open -> closed.
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	fail := errors.New("boom")
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("expected request %d to be allowed, got %v", i, err)
+		}
+		b.Record(fail)
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("expected open after 2 failures, got %s", b.State())
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expected half-open after cooldown, got %s", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected only one probe in half-open, got %v", err)
+	}
+	b.Record(nil)
+	if b.State() != StateClosed {
+		t.Fatalf("expected closed after successful probe, got %s", b.State())
+	}
+}
+
+// TestBreakerProvider_ShortCircuits проверяет, что разомкнутый выключатель не вызывает провайдера.
+func TestBreakerProvider_ShortCircuits(t *testing.T) {
+	inner := &stubProvider{name: "nationalize", field: FieldNationality, err: ErrUpstreamUnavailable}
+	p := NewBreakerProvider(inner, NewBreaker(1, time.Hour))
+
+	if _, err := p.Enrich(context.Background(), Query{Name: "ivan"}); !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("expected upstream error on first call, got %v", err)
+	}
+	inner.err = nil
+	if _, err := p.Enrich(context.Background(), Query{Name: "ivan"}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen on second call, got %v", err)
+	}
+
+	st := NewCompositeEnricher(p).BreakerStatuses()
+	if len(st) != 1 || st[0].Provider != "nationalize" || st[0].State != "open" {
+		t.Errorf("unexpected breaker statuses: %+v", st)
+	}
+}
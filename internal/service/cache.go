@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"effect/internal/model"
+)
+
+// Cache хранит результаты обогащения по ключу Query.Key (нормализованное имя и страна).
+// Реализации сами отвечают за срок жизни записей (TTL).
+type Cache interface {
+	// Get возвращает результат из кэша; found=false, если записи нет или она устарела.
+	Get(ctx context.Context, key string) (res *EnrichResult, found bool, err error)
+	// Set сохраняет результат в кэш.
+	Set(ctx context.Context, key string, res *EnrichResult) error
+}
+
+// CacheStats содержит счетчики попаданий и промахов кэша.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// CachedEnricher оборачивает Enricher и кэширует его полные результаты.
+// Ошибки кэша не прерывают обогащение: запрос уходит к следующему Enricher.
+type CachedEnricher struct {
+	Next  Enricher
+	Cache Cache
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewCachedEnricher создает CachedEnricher поверх next с указанным хранилищем.
+func NewCachedEnricher(next Enricher, cache Cache) *CachedEnricher {
+	return &CachedEnricher{Next: next, Cache: cache}
+}
+
+// Enrich возвращает результат из кэша, а при промахе вызывает следующий Enricher и сохраняет ответ.
+func (c *CachedEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	key := q.Key()
+
+	res, found, err := c.Cache.Get(ctx, key)
+	if err != nil {
+		log.WithError(err).Warnf("service.CachedEnricher: cache get failed for key=%s", key)
+	}
+	if found {
+		c.hits.Add(1)
+		log.Debugf("service.CachedEnricher: cache hit for key=%s", key)
+		return fromCache(res), nil
+	}
+	c.misses.Add(1)
+	log.Debugf("service.CachedEnricher: cache miss for key=%s", key)
+
+	res, err = c.Next.Enrich(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	// неполный результат не кэшируем, чтобы следующий запрос снова обратился к провайдерам
+	if len(res.Missing) > 0 {
+		return res, nil
+	}
+
+	if err := c.Cache.Set(ctx, key, res); err != nil {
+		log.WithError(err).Warnf("service.CachedEnricher: cache set failed for key=%s", key)
+	}
+	return res, nil
+}
+
+// fromCache возвращает копию результата из кэша, в которой источником полей указан кэш.
+// Провайдер, исходный ответ и время получения сохраняются.
+func fromCache(res *EnrichResult) *EnrichResult {
+	if res == nil {
+		return nil
+	}
+	out := *res
+	out.Provenance = make(model.Provenance, len(res.Provenance))
+	for field, fp := range res.Provenance {
+		fp.Source = model.SourceCache
+		out.Provenance[field] = fp
+	}
+	return &out
+}
+
+// Stats возвращает текущие значения счетчиков попаданий и промахов.
+func (c *CachedEnricher) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
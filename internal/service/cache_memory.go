@@ -0,0 +1,90 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryCache — LRU-кэш в памяти процесса с ограничением размера и TTL.
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+
+	// now используется для подмены времени в тестах.
+	now func() time.Time
+}
+
+type memoryEntry struct {
+	key       string
+	res       *EnrichResult
+	expiresAt time.Time
+}
+
+// NewMemoryCache создает LRU-кэш на capacity записей.
+// Если ttl <= 0, записи не устаревают.
+func NewMemoryCache(capacity int, ttl time.Duration) *MemoryCache {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &MemoryCache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (*EnrichResult, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if c.ttl > 0 && c.now().After(e.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false, nil
+	}
+
+	c.ll.MoveToFront(el)
+	return e.res, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, res *EnrichResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*memoryEntry)
+		e.res, e.expiresAt = res, expiresAt
+		c.ll.MoveToFront(el)
+		return nil
+	}
+
+	c.items[key] = c.ll.PushFront(&memoryEntry{key: key, res: res, expiresAt: expiresAt})
+
+	// вытесняем самую давно использованную запись
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+// Len возвращает количество записей в кэше.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// cacheSweepInterval — минимальный интервал между удалениями устаревших записей кэша.
+const cacheSweepInterval = time.Minute
+
+// PostgresCache хранит результаты обогащения в таблице enrichment_cache.
+// Устаревшие записи удаляются в Set не чаще раза в cacheSweepInterval.
+type PostgresCache struct {
+	DB  *sql.DB
+	TTL time.Duration
+
+	mu        sync.Mutex
+	nextSweep time.Time
+}
+
+// NewPostgresCache создает кэш поверх соединения с PostgreSQL.
+// Если ttl <= 0, записи не устаревают.
+func NewPostgresCache(db *sql.DB, ttl time.Duration) *PostgresCache {
+	return &PostgresCache{DB: db, TTL: ttl}
+}
+
+func (c *PostgresCache) Get(ctx context.Context, key string) (*EnrichResult, bool, error) {
+	var (
+		raw       []byte
+		updatedAt time.Time
+	)
+	err := c.DB.QueryRowContext(ctx,
+		`SELECT result, updated_at FROM enrichment_cache WHERE name=$1`, key,
+	).Scan(&raw, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if c.TTL > 0 && time.Since(updatedAt) > c.TTL {
+		return nil, false, nil
+	}
+
+	var res EnrichResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, false, err
+	}
+	return &res, true, nil
+}
+
+func (c *PostgresCache) Set(ctx context.Context, key string, res *EnrichResult) error {
+	raw, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	_, err = c.DB.ExecContext(ctx, `
+		INSERT INTO enrichment_cache (name, result, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (name) DO UPDATE SET result = EXCLUDED.result, updated_at = EXCLUDED.updated_at`,
+		key, raw,
+	)
+	if err != nil {
+		return err
+	}
+	c.sweep(ctx)
+	return nil
+}
+
+// sweep удаляет записи старше TTL, если с прошлого удаления прошло не меньше cacheSweepInterval.
+// Ошибка удаления только логируется: запись в кэш уже выполнена.
+func (c *PostgresCache) sweep(ctx context.Context) {
+	if c.TTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	now := time.Now()
+	if now.Before(c.nextSweep) {
+		c.mu.Unlock()
+		return
+	}
+	c.nextSweep = now.Add(cacheSweepInterval)
+	c.mu.Unlock()
+
+	res, err := c.DB.ExecContext(ctx,
+		`DELETE FROM enrichment_cache WHERE updated_at < NOW() - make_interval(secs => $1)`, c.TTL.Seconds(),
+	)
+	if err != nil {
+		log.WithError(err).Warn("service.PostgresCache: failed to delete expired entries")
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Debugf("service.PostgresCache: deleted %d expired entries", n)
+	}
+}
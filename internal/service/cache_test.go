@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingEnricher считает вызовы и возвращает фиксированный результат.
+type countingEnricher struct {
+	calls int
+	res   *EnrichResult
+}
+
+func (e *countingEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	e.calls++
+	return e.res, nil
+}
+
+// TestMemoryCache_EvictsLeastRecentlyUsed проверяет, что при переполнении вытесняется самая давно использованная запись.
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(2, 0)
+
+	c.Set(ctx, "a", &EnrichResult{})
+	c.Set(ctx, "b", &EnrichResult{})
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", &EnrichResult{})
+
+	if _, found, _ := c.Get(ctx, "b"); found {
+		t.Error("expected b to be evicted")
+	}
+	if _, found, _ := c.Get(ctx, "a"); !found {
+		t.Error("expected a to stay in cache")
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+// TestMemoryCache_TTL проверяет, что устаревшие записи не возвращаются.
+func TestMemoryCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewMemoryCache(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", &EnrichResult{})
+	now = now.Add(2 * time.Minute)
+
+	if _, found, _ := c.Get(ctx, "a"); found {
+		t.Error("expected expired entry to be missing")
+	}
+}
+
+// TestCachedEnricher_HitMiss проверяет, что повторный запрос того же имени обслуживается из кэша
+// и учитывается в счетчиках.
+func TestCachedEnricher_HitMiss(t *testing.T) {
+	age := 25
+	next := &countingEnricher{res: &EnrichResult{Age: &age}}
+	e := NewCachedEnricher(next, NewMemoryCache(10, time.Hour))
+
+	for _, name := range []string{"Anna", " anna ", "ANNA"} {
+		res, err := e.Enrich(context.Background(), Query{Name: name})
+		if err != nil {
+			t.Fatalf("Enrich returned error: %v", err)
+		}
+		if res.Age == nil || *res.Age != 25 {
+			t.Errorf("expected Age=25, got %v", res.Age)
+		}
+	}
+
+	if next.calls != 1 {
+		t.Errorf("expected 1 upstream call, got %d", next.calls)
+	}
+	if st := e.Stats(); st.Hits != 2 || st.Misses != 1 {
+		t.Errorf("expected hits=2 misses=1, got %+v", st)
+	}
+}
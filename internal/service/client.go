@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrRateLimited возвращается, когда внешний API ответил 429 и повторы не помогли.
+	ErrRateLimited = errors.New("upstream rate limited")
+	// ErrUpstreamUnavailable возвращается при 5xx или сетевой ошибке после всех повторов.
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+)
+
+// UpstreamError описывает неуспешный ответ внешнего API.
+// Для 429 и 5xx Err содержит ErrRateLimited или ErrUpstreamUnavailable.
+type UpstreamError struct {
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+func (e *UpstreamError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("GET %s: %v", e.URL, e.Err)
+	}
+	return fmt.Sprintf("GET %s: status %d", e.URL, e.StatusCode)
+}
+
+func (e *UpstreamError) Unwrap() error { return e.Err }
+
+// Значения APIClient по умолчанию.
+const (
+	DefaultAPITimeout  = 10 * time.Second
+	DefaultMaxRetries  = 3
+	DefaultBaseBackoff = 200 * time.Millisecond
+	DefaultMaxBackoff  = 5 * time.Second
+)
+
+// APIClient выполняет GET-запросы к внешним API с проверкой статуса ответа
+// и повторами для 429 и 5xx с экспоненциальной задержкой и джиттером.
+type APIClient struct {
+	HTTP        *http.Client
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Quota отслеживает квоту провайдера по заголовкам ответов; nil отключает учет.
+	Quota *Quota
+
+	// sleep используется для подмены ожидания в тестах.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewAPIClient создает клиент с указанным таймаутом запроса и настройками повторов по умолчанию.
+func NewAPIClient(timeout time.Duration) *APIClient {
+	if timeout <= 0 {
+		timeout = DefaultAPITimeout
+	}
+	return &APIClient{
+		HTTP:        &http.Client{Timeout: timeout},
+		MaxRetries:  DefaultMaxRetries,
+		BaseBackoff: DefaultBaseBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		sleep:       sleepCtx,
+	}
+}
+
+// defaultClient используется провайдерами, для которых клиент не задан явно.
+var defaultClient = NewAPIClient(DefaultAPITimeout)
+
+func clientOrDefault(c *APIClient) *APIClient {
+	if c == nil {
+		return defaultClient
+	}
+	return c
+}
+
+// callAPI отправляет GET запрос на указанный URL и декодирует ответ в указанный интерфейс.
+// Ответы 429 и 5xx, а также сетевые ошибки повторяются до MaxRetries раз; Retry-After длиннее
+// MaxBackoff не выжидается, а ошибка возвращается сразу.
+// Если квота провайдера исчерпана, запрос не отправляется и возвращается *QuotaError.
+// Если тело ответа пустое (EOF), ошибка игнорируется.
+func (c *APIClient) callAPI(ctx context.Context, rawURL string, out interface{}) error {
+	if err := c.allow(); err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.do(ctx, rawURL, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		if !isRetryable(err) || attempt >= c.MaxRetries || ctx.Err() != nil {
+			return lastErr
+		}
+		// при исчерпанной квоте повторы до ее сброса бессмысленны
+		if err := c.allow(); err != nil {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		if retryAfter > 0 && c.MaxBackoff > 0 && retryAfter > c.MaxBackoff {
+			// ждать дольше MaxBackoff внутри запроса нельзя: ошибка возвращается сразу
+			log.WithError(err).Warnf("service.callAPI: GET %s asked to retry in %s, longer than %s, giving up",
+				safeURL(rawURL), retryAfter, c.MaxBackoff)
+			return lastErr
+		}
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		log.WithError(err).Warnf("service.callAPI: retrying GET %s in %s (attempt %d/%d)",
+			safeURL(rawURL), delay, attempt+1, c.MaxRetries)
+		if err := c.sleep(ctx, delay); err != nil {
+			return lastErr
+		}
+	}
+}
+
+// do выполняет одну попытку запроса и возвращает задержку из заголовка Retry-After, если он был.
+func (c *APIClient) do(ctx context.Context, rawURL string, out interface{}) (time.Duration, error) {
+	log.Debugf("service.callAPI: GET %s", safeURL(rawURL))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		// *url.Error содержит полный адрес вместе с ключом API
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return 0, &UpstreamError{URL: safeURL(rawURL), Err: fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err)}
+	}
+	defer resp.Body.Close()
+	if c.Quota != nil {
+		c.Quota.Update(resp.Header)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		io.Copy(io.Discard, resp.Body)
+		return parseRetryAfter(resp.Header.Get("Retry-After")),
+			&UpstreamError{URL: safeURL(rawURL), StatusCode: resp.StatusCode, Err: ErrRateLimited}
+	case resp.StatusCode >= 500:
+		io.Copy(io.Discard, resp.Body)
+		return parseRetryAfter(resp.Header.Get("Retry-After")),
+			&UpstreamError{URL: safeURL(rawURL), StatusCode: resp.StatusCode, Err: ErrUpstreamUnavailable}
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		io.Copy(io.Discard, resp.Body)
+		return 0, &UpstreamError{URL: safeURL(rawURL), StatusCode: resp.StatusCode}
+	}
+
+	// apiResponse получает тело ответа без разбора вместе с идентификатором запроса
+	if r, ok := out.(*apiResponse); ok {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, err
+		}
+		r.ID, r.Body = responseID(resp.Header), body
+		return 0, nil
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	if err := dec.Decode(out); err != nil {
+		// если тело было пустое — игнорируем
+		if err == io.EOF {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+// apiResponse — тело ответа внешнего API без разбора и идентификатор запроса провайдера.
+// Используется, когда исходный ответ нужно сохранить (см. model.FieldProvenance).
+type apiResponse struct {
+	ID   string
+	Body json.RawMessage
+}
+
+// decode разбирает тело ответа в out; пустое тело оставляет out без изменений.
+func (r *apiResponse) decode(out interface{}) error {
+	if len(r.Body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Body, out)
+}
+
+// responseID возвращает идентификатор запроса из заголовков ответа, если провайдер его сообщает.
+func responseID(h http.Header) string {
+	for _, k := range []string{"X-Request-Id", "CF-Ray"} {
+		if v := h.Get(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// allow проверяет квоту провайдера перед запросом.
+func (c *APIClient) allow() error {
+	if c.Quota == nil {
+		return nil
+	}
+	return c.Quota.Allow()
+}
+
+// backoff возвращает задержку перед повтором с номером attempt (full jitter).
+func (c *APIClient) backoff(attempt int) time.Duration {
+	d := c.BaseBackoff << attempt
+	if d <= 0 || d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+// safeURL скрывает значение параметра apikey, чтобы ключ не попадал в логи и тексты ошибок.
+func safeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	q := u.Query()
+	if q.Get("apikey") == "" {
+		return raw
+	}
+	q.Set("apikey", "***")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUpstreamUnavailable)
+}
+
+// parseRetryAfter разбирает заголовок Retry-After в виде числа секунд или HTTP-даты.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
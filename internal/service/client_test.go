@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// instantClient возвращает клиент, который не ждет между повторами, и записывает запрошенные задержки.
+func instantClient(delays *[]time.Duration) *APIClient {
+	c := NewAPIClient(time.Second)
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		if delays != nil {
+			*delays = append(*delays, d)
+		}
+		return nil
+	}
+	return c
+}
+
+// TestCallAPI_RetriesServerErrors проверяет, что 5xx повторяется и успешный ответ после сбоя возвращается.
+func TestCallAPI_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"age":30}`)
+	}))
+	defer srv.Close()
+
+	var out struct{ Age int }
+	if err := instantClient(nil).callAPI(context.Background(), srv.URL, &out); err != nil {
+		t.Fatalf("callAPI returned error: %v", err)
+	}
+	if out.Age != 30 || calls.Load() != 3 {
+		t.Errorf("expected Age=30 after 3 calls, got Age=%d calls=%d", out.Age, calls.Load())
+	}
+}
+
+// TestCallAPI_RateLimitedHonorsRetryAfter проверяет, что для 429 используется задержка из Retry-After,
+// а после исчерпания повторов возвращается ErrRateLimited.
+func TestCallAPI_RateLimitedHonorsRetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	var delays []time.Duration
+	var out struct{}
+	err := instantClient(&delays).callAPI(context.Background(), srv.URL, &out)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if len(delays) != DefaultMaxRetries {
+		t.Fatalf("expected %d retries, got %d", DefaultMaxRetries, len(delays))
+	}
+	for _, d := range delays {
+		if d != 2*time.Second {
+			t.Errorf("expected Retry-After delay 2s, got %s", d)
+		}
+	}
+}
+
+// TestCallAPI_LongRetryAfterNotAwaited проверяет, что Retry-After длиннее MaxBackoff не выжидается:
+// ошибка возвращается без повторов.
+func TestCallAPI_LongRetryAfterNotAwaited(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	var delays []time.Duration
+	var out struct{}
+	err := instantClient(&delays).callAPI(context.Background(), srv.URL, &out)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if calls.Load() != 1 || len(delays) != 0 {
+		t.Errorf("expected a single call without sleeping, got %d calls and delays %v", calls.Load(), delays)
+	}
+}
+
+// TestCallAPI_ClientErrorNotRetried проверяет, что 4xx (кроме 429) не повторяется и возвращает UpstreamError.
+func TestCallAPI_ClientErrorNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	var out struct{}
+	err := instantClient(nil).callAPI(context.Background(), srv.URL, &out)
+
+	var ue *UpstreamError
+	if !errors.As(err, &ue) || ue.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected UpstreamError with status 400, got %v", err)
+	}
+	if isRetryable(err) || calls.Load() != 1 {
+		t.Errorf("expected single non-retryable call, got calls=%d", calls.Load())
+	}
+}
+
+// TestProvider_SendsAPIKey проверяет, что ключ API передается провайдеру, но не попадает в текст ошибки.
+func TestProvider_SendsAPIKey(t *testing.T) {
+	var gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.URL.Query().Get("apikey")
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	p := &GenderizeProvider{BaseURL: srv.URL, APIKey: "secret-key", Client: instantClient(nil)}
+	_, err := p.Enrich(context.Background(), Query{Name: "anna"})
+
+	if gotKey != "secret-key" {
+		t.Errorf("expected apikey=secret-key, got %q", gotKey)
+	}
+	if err == nil || strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("expected error without API key, got %v", err)
+	}
+}
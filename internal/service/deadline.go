@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// DeadlineEnricher ограничивает общее время обогащения: по истечении Timeout
+// контекст отменяется, и все незавершенные запросы к провайдерам прерываются.
+type DeadlineEnricher struct {
+	Next    Enricher
+	Timeout time.Duration
+}
+
+// NewDeadlineEnricher оборачивает next; timeout <= 0 отключает ограничение.
+func NewDeadlineEnricher(next Enricher, timeout time.Duration) *DeadlineEnricher {
+	return &DeadlineEnricher{Next: next, Timeout: timeout}
+}
+
+func (e *DeadlineEnricher) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, e.Timeout)
+}
+
+func (e *DeadlineEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	ctx, cancel := e.withDeadline(ctx)
+	defer cancel()
+	return e.Next.Enrich(ctx, q)
+}
+
+// EnrichBatch применяет тот же срок ко всему пакету.
+func (e *DeadlineEnricher) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	ctx, cancel := e.withDeadline(ctx)
+	defer cancel()
+	return EnrichAll(ctx, e.Next, queries)
+}
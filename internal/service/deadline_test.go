@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingEnricher ждет отмены контекста и возвращает его ошибку.
+type blockingEnricher struct{}
+
+func (blockingEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestDeadlineEnricher_Timeout проверяет, что обогащение прерывается по истечении срока.
+func TestDeadlineEnricher_Timeout(t *testing.T) {
+	e := NewDeadlineEnricher(blockingEnricher{}, 10*time.Millisecond)
+
+	start := time.Now()
+	_, err := e.Enrich(context.Background(), Query{Name: "ivan"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("enrichment was not cancelled in time: %s", elapsed)
+	}
+}
+
+// TestDeadlineEnricher_ParentCancel проверяет, что отмена родительского контекста
+// (например, клиент закрыл соединение) прерывает обогащение и без заданного срока.
+func TestDeadlineEnricher_ParentCancel(t *testing.T) {
+	e := NewDeadlineEnricher(blockingEnricher{}, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := e.Enrich(ctx, Query{Name: "ivan"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
@@ -1,13 +1,14 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
+	"sort"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
+
+	"effect/internal/model"
 )
 
 // EnrichResult представляет результат обогащения данных.
@@ -15,125 +16,171 @@ type EnrichResult struct {
 	Age         *int    `json:"age"`
 	Gender      *string `json:"gender"`
 	Nationality *string `json:"nationality"`
+
+	// AgeCount — размер выборки Agify для имени.
+	AgeCount *int `json:"age_count,omitempty"`
+	// GenderProbability и GenderCount — вероятность и размер выборки Genderize.
+	GenderProbability *float64 `json:"gender_probability,omitempty"`
+	GenderCount       *int     `json:"gender_count,omitempty"`
+	// NationalityCandidates — полный ранжированный список стран из ответа Nationalize, NationalityCount — размер выборки.
+	NationalityCandidates model.NationalityCandidates `json:"nationality_candidates,omitempty"`
+	NationalityCount      *int                        `json:"nationality_count,omitempty"`
+
+	// Missing перечисляет поля, которые не удалось получить (см. PolicyBestEffort и PolicySkip).
+	Missing []string `json:"missing,omitempty"`
+	// LowConfidence перечисляет поля, отброшенные из-за порогов достоверности (см. Thresholds).
+	LowConfidence []string `json:"low_confidence,omitempty"`
+
+	// Source — источник результата: SourceAPI или SourceFallback, если хотя бы одно поле
+	// взято из локального словаря; сами такие поля перечислены в FallbackFields.
+	Source         string   `json:"source,omitempty"`
+	FallbackFields []string `json:"fallback_fields,omitempty"`
+
+	// Provenance — происхождение каждого полученного поля: источник, провайдер и исходный ответ.
+	Provenance model.Provenance `json:"provenance,omitempty"`
 }
 
-// Enrich обогащает данные о человеке, используя API Agify, Genderize и Nationalize.
-// Возвращает результат обогащения и ошибку, если таковая возникла.
-func Enrich(name string) (*EnrichResult, error) {
-	log.Debugf("service.Enrich: starting enrichment for name=%s", name)
+// Поля, заполняемые при обогащении.
+const (
+	FieldAge         = "age"
+	FieldGender      = "gender"
+	FieldNationality = "nationality"
+)
 
-	var (
-		wg  sync.WaitGroup
-		res EnrichResult
-		mu  sync.Mutex
-		err error
-	)
+// Policy определяет, как CompositeEnricher обрабатывает ошибки провайдеров.
+type Policy string
 
-	wg.Add(3)
+const (
+	// PolicyStrict — ошибка любого провайдера является ошибкой всего обогащения.
+	PolicyStrict Policy = "strict"
+	// PolicyBestEffort — сохраняются успешно полученные поля, остальные попадают в Missing.
+	PolicyBestEffort Policy = "best-effort"
+	// PolicySkip — провайдеры не вызываются, все поля попадают в Missing.
+	PolicySkip Policy = "skip"
+)
 
-	// Agify
-	go func() {
-		defer wg.Done()
-		url := fmt.Sprintf("https://api.agify.io/?name=%s", name)
-		log.Debugf("service.Enrich: calling Agify API: %s", url)
+// ParsePolicy разбирает название политики обогащения.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyStrict, PolicyBestEffort, PolicySkip:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown enrichment policy %q", s)
+}
 
-		var a struct {
-			Age *int `json:"age"`
-		}
-		if e := callAPI(url, &a); e != nil {
-			mu.Lock()
-			err = e
-			mu.Unlock()
-			log.WithError(e).Error("service.Enrich: Agify call failed")
-			return
-		}
+// Enricher обогащает данные о человеке по имени.
+type Enricher interface {
+	Enrich(ctx context.Context, q Query) (*EnrichResult, error)
+}
 
-		mu.Lock()
-		res.Age = a.Age
-		mu.Unlock()
-		log.Debugf("service.Enrich: Agify result: %v", a.Age)
-	}()
+// Provider — отдельный источник обогащения (Agify, Genderize, Nationalize и т.п.).
+// Провайдер заполняет только те поля EnrichResult, за которые он отвечает.
+type Provider interface {
+	Enricher
+	Name() string
+	// Field возвращает поле EnrichResult, за которое отвечает провайдер.
+	Field() string
+}
 
-	// Genderize
-	go func() {
-		defer wg.Done()
-		url := fmt.Sprintf("https://api.genderize.io/?name=%s", name)
-		log.Debugf("service.Enrich: calling Genderize API: %s", url)
+// CompositeEnricher опрашивает набор провайдеров параллельно и объединяет их результаты.
+type CompositeEnricher struct {
+	Providers []Provider
+	Policy    Policy
+	// BatchConcurrency ограничивает число одновременных пакетных запросов в EnrichBatch.
+	BatchConcurrency int
+}
 
-		var g struct {
-			Gender *string `json:"gender"`
-		}
-		if e := callAPI(url, &g); e != nil {
-			mu.Lock()
-			err = e
-			mu.Unlock()
-			log.WithError(e).Error("service.Enrich: Genderize call failed")
-			return
-		}
+// NewCompositeEnricher создает CompositeEnricher из переданных провайдеров со строгой политикой.
+func NewCompositeEnricher(providers ...Provider) *CompositeEnricher {
+	return &CompositeEnricher{Providers: providers, Policy: PolicyStrict}
+}
 
-		mu.Lock()
-		res.Gender = g.Gender
-		mu.Unlock()
-		log.Debugf("service.Enrich: Genderize result: %v", g.Gender)
-	}()
-
-	// Nationalize
-	go func() {
-		defer wg.Done()
-		url := fmt.Sprintf("https://api.nationalize.io/?name=%s", name)
-		log.Debugf("service.Enrich: calling Nationalize API: %s", url)
-
-		var n struct {
-			Country []struct {
-				CountryID   string  `json:"country_id"`
-				Probability float64 `json:"probability"`
-			} `json:"country"`
-		}
-		if e := callAPI(url, &n); e != nil {
-			mu.Lock()
-			err = e
-			mu.Unlock()
-			log.WithError(e).Error("service.Enrich: Nationalize call failed")
-			return
+// NewDefaultEnricher создает CompositeEnricher с публичными API Agify, Genderize и Nationalize.
+func NewDefaultEnricher() *CompositeEnricher {
+	client := NewAPIClient(DefaultAPITimeout)
+	return NewCompositeEnricher(
+		NewAgifyProvider(client),
+		NewGenderizeProvider(client),
+		NewNationalizeProvider(client),
+	)
+}
+
+// Enrich обогащает данные о человеке, опрашивая всех провайдеров параллельно.
+// При строгой политике возвращает ошибку, если хотя бы один провайдер завершился с ошибкой;
+// при PolicyBestEffort поля упавших провайдеров перечисляются в Missing.
+func (c *CompositeEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	if c.Policy == PolicySkip {
+		log.Debugf("service.Enrich: enrichment skipped for name=%s", q.Name)
+		res := &EnrichResult{}
+		for _, p := range c.Providers {
+			res.Missing = append(res.Missing, p.Field())
 		}
+		return res, nil
+	}
+
+	log.Debugf("service.Enrich: starting enrichment for name=%s country=%s", q.Name, q.CountryID)
+
+	var (
+		wg  sync.WaitGroup
+		res EnrichResult
+		mu  sync.Mutex
+		err error
+	)
+
+	wg.Add(len(c.Providers))
+	for _, p := range c.Providers {
+		go func(p Provider) {
+			defer wg.Done()
+
+			r, e := p.Enrich(ctx, q)
+			if e != nil {
+				mu.Lock()
+				err = e
+				res.Missing = append(res.Missing, p.Field())
+				mu.Unlock()
+				log.WithError(e).Errorf("service.Enrich: %s call failed", p.Name())
+				return
+			}
 
-		if len(n.Country) > 0 {
 			mu.Lock()
-			res.Nationality = &n.Country[0].CountryID
+			mergeResult(&res, r)
 			mu.Unlock()
-			log.Debugf("service.Enrich: Nationalize result: %v", n.Country[0].CountryID)
-		}
-	}()
+		}(p)
+	}
 
 	wg.Wait()
 
-	if err != nil {
+	if err != nil && c.Policy != PolicyBestEffort {
 		log.WithError(err).Error("service.Enrich: enrichment failed")
 		return nil, err
 	}
+	if len(res.Missing) > 0 {
+		sort.Strings(res.Missing)
+		log.Warnf("service.Enrich: partial enrichment for name=%s, missing=%v", q.Name, res.Missing)
+	}
 
-	log.Debugf("service.Enrich: completed enrichment for name=%s: %+v", name, res)
+	log.Debugf("service.Enrich: completed enrichment for name=%s: %+v", q.Name, res)
 	return &res, nil
 }
 
-// callAPI отправляет GET запрос на указанный URL и декодирует ответ в указанный интерфейс.
-// Если тело ответа пустое (EOF), ошибка игнорируется.
-func callAPI(url string, out interface{}) error {
-	log.Debugf("service.callAPI: GET %s", url)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+// mergeResult переносит в dst все непустые поля src.
+func mergeResult(dst, src *EnrichResult) {
+	if src == nil {
+		return
 	}
-	defer resp.Body.Close()
-
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(out); err != nil {
-		// если тело было пустое — игнорируем
-		if err == io.EOF {
-			return nil
+	if src.Age != nil {
+		dst.Age, dst.AgeCount = src.Age, src.AgeCount
+	}
+	if src.Gender != nil {
+		dst.Gender, dst.GenderProbability, dst.GenderCount = src.Gender, src.GenderProbability, src.GenderCount
+	}
+	if src.Nationality != nil {
+		dst.Nationality, dst.NationalityCandidates, dst.NationalityCount = src.Nationality, src.NationalityCandidates, src.NationalityCount
+	}
+	for field, fp := range src.Provenance {
+		if dst.Provenance == nil {
+			dst.Provenance = model.Provenance{}
 		}
-		return err
+		dst.Provenance[field] = fp
 	}
-	return nil
 }
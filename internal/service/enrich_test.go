@@ -1,32 +1,16 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
 	"testing"
 	"time"
 )
 
-// rewriteTransport перенаправляет запросы к разным хостам на соответствующие тестовые серверы.
-type rewriteTransport struct {
-	base    http.RoundTripper
-	mapping map[string]string
-}
-
-func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	// Оригинальный хост, по которому строился URL
-	origHost := req.URL.Host
-	if newHost, ok := t.mapping[origHost]; ok {
-		req.URL.Scheme = "http"
-		req.URL.Host = newHost
-	}
-	return t.base.RoundTrip(req)
-}
-
 // TestCallAPI_Success тестирует функцию callAPI с корректным JSON ответом.
 // Ожидается, что функция успешно декодирует JSON и вернет ожидаемый результат.
 func TestCallAPI_Success(t *testing.T) {
@@ -38,7 +22,7 @@ func TestCallAPI_Success(t *testing.T) {
 	defer srv.Close()
 
 	var out struct{ Foo string }
-	if err := callAPI(srv.URL, &out); err != nil {
+	if err := NewAPIClient(time.Second).callAPI(context.Background(), srv.URL, &out); err != nil {
 		t.Fatalf("callAPI returned error: %v", err)
 	}
 
@@ -56,48 +40,40 @@ func TestCallAPI_NonJSON(t *testing.T) {
 	defer srv.Close()
 
 	var out struct{ Foo string }
-	err := callAPI(srv.URL, &out)
+	err := NewAPIClient(time.Second).callAPI(context.Background(), srv.URL, &out)
 
 	if err == nil {
 		t.Fatal("expected error decoding non-JSON, got nil")
 	}
 }
 
-// TestEnrich_Success тестирует функцию Enrich в случае успешного выполнения.
-// Он создает тестовые серверы для agify, genderize и nationalize, которые имитируют ответы API.
-// Затем он настраивает http.DefaultClient для использования этих тестовых серверов.
-// Наконец, он вызывает функцию Enrich и проверяет, что она возвращает ожидаемые результаты.
+// TestEnrich_Success тестирует CompositeEnricher в случае успешного выполнения.
+// Он создает тестовые серверы для agify, genderize и nationalize, которые имитируют ответы API,
+// и направляет на них провайдеров через BaseURL.
+// Наконец, он вызывает Enrich и проверяет, что он возвращает ожидаемые результаты.
 func TestEnrich_Success(t *testing.T) {
 	agify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, `{"age":30}`)
+		fmt.Fprint(w, `{"age":30,"count":1200}`)
 	}))
 	defer agify.Close()
 
 	genderize := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, `{"gender":"male"}`)
+		fmt.Fprint(w, `{"gender":"male","probability":0.98,"count":5400}`)
 	}))
 	defer genderize.Close()
 
 	nationalize := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, `{"country":[{"country_id":"US","probability":0.9}]}`)
+		fmt.Fprint(w, `{"count":870,"country":[{"country_id":"US","probability":0.9},{"country_id":"GB","probability":0.05}]}`)
 	}))
 	defer nationalize.Close()
 
-	origClient := http.DefaultClient
-	http.DefaultClient = &http.Client{
-		Transport: &rewriteTransport{
-			base: http.DefaultTransport,
-			mapping: map[string]string{
-				"api.agify.io":       mustHostPort(agify.URL),
-				"api.genderize.io":   mustHostPort(genderize.URL),
-				"api.nationalize.io": mustHostPort(nationalize.URL),
-			},
-		},
-		Timeout: 2 * time.Second,
-	}
-	defer func() { http.DefaultClient = origClient }()
-
-	res, err := Enrich("john")
+	e := NewCompositeEnricher(
+		&AgifyProvider{BaseURL: agify.URL},
+		&GenderizeProvider{BaseURL: genderize.URL},
+		&NationalizeProvider{BaseURL: nationalize.URL},
+	)
+
+	res, err := e.Enrich(context.Background(), Query{Name: "john"})
 	if err != nil {
 		t.Fatalf("Enrich returned error: %v", err)
 	}
@@ -110,12 +86,22 @@ func TestEnrich_Success(t *testing.T) {
 	if res.Nationality == nil || *res.Nationality != "US" {
 		t.Errorf("expected Nationality=US, got %v", res.Nationality)
 	}
+	if res.AgeCount == nil || *res.AgeCount != 1200 {
+		t.Errorf("expected AgeCount=1200, got %v", res.AgeCount)
+	}
+	if res.GenderProbability == nil || *res.GenderProbability != 0.98 || res.GenderCount == nil || *res.GenderCount != 5400 {
+		t.Errorf("expected gender probability 0.98 and count 5400, got %v/%v", res.GenderProbability, res.GenderCount)
+	}
+	if len(res.NationalityCandidates) != 2 || res.NationalityCandidates[1].CountryID != "GB" {
+		t.Errorf("expected 2 nationality candidates, got %+v", res.NationalityCandidates)
+	}
+	if res.NationalityCount == nil || *res.NationalityCount != 870 {
+		t.Errorf("expected NationalityCount=870, got %v", res.NationalityCount)
+	}
 }
 
-// TestEnrich_PartialFailure тестирует функцию Enrich в случае частичного сбоя.
-// Он создает тестовые серверы для agify, genderize и nationalize, которые имитируют ответы API.
-// Затем он настраивает http.DefaultClient для использования этих тестовых серверов.
-// Наконец, он вызывает функцию Enrich и проверяет, что она возвращает ошибку, когда один из API возвращает 500.
+// TestEnrich_PartialFailure тестирует CompositeEnricher в случае частичного сбоя.
+// Ожидается, что Enrich вернет ошибку, когда один из API возвращает 500.
 func TestEnrich_PartialFailure(t *testing.T) {
 	agify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "fail", http.StatusInternalServerError)
@@ -132,36 +118,147 @@ func TestEnrich_PartialFailure(t *testing.T) {
 	}))
 	defer nationalize.Close()
 
-	http.DefaultClient = &http.Client{
-		Transport: &rewriteTransport{
-			base: http.DefaultTransport,
-			mapping: map[string]string{
-				"api.agify.io":       mustHostPort(agify.URL),
-				"api.genderize.io":   mustHostPort(genderize.URL),
-				"api.nationalize.io": mustHostPort(nationalize.URL),
-			},
-		},
-		Timeout: 2 * time.Second,
+	e := NewCompositeEnricher(
+		&AgifyProvider{BaseURL: agify.URL, Client: instantClient(nil)},
+		&GenderizeProvider{BaseURL: genderize.URL},
+		&NationalizeProvider{BaseURL: nationalize.URL},
+	)
+
+	_, err := e.Enrich(context.Background(), Query{Name: "alice"})
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("expected ErrUpstreamUnavailable when one of APIs вернул 500, got %v", err)
 	}
+}
 
-	_, err := Enrich("alice")
-	if err == nil {
-		t.Fatal("expected error when one of APIs вернул 500, got nil")
+// stubProvider — детерминированный провайдер для тестов без HTTP.
+type stubProvider struct {
+	name  string
+	field string
+	res   *EnrichResult
+	err   error
+}
+
+func (p *stubProvider) Name() string  { return p.name }
+func (p *stubProvider) Field() string { return p.field }
+
+func (p *stubProvider) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	return p.res, p.err
+}
+
+// TestComposite_MergesProviders проверяет, что CompositeEnricher объединяет поля разных провайдеров
+// и работает с любым подмножеством провайдеров.
+func TestComposite_MergesProviders(t *testing.T) {
+	age, gender := 42, "female"
+	e := NewCompositeEnricher(
+		&stubProvider{name: "age", field: FieldAge, res: &EnrichResult{Age: &age}},
+		&stubProvider{name: "gender", field: FieldGender, res: &EnrichResult{Gender: &gender}},
+	)
+
+	res, err := e.Enrich(context.Background(), Query{Name: "anna"})
+	if err != nil {
+		t.Fatalf("Enrich returned error: %v", err)
+	}
+	if res.Age == nil || *res.Age != 42 {
+		t.Errorf("expected Age=42, got %v", res.Age)
+	}
+	if res.Gender == nil || *res.Gender != "female" {
+		t.Errorf("expected Gender=female, got %v", res.Gender)
+	}
+	if res.Nationality != nil {
+		t.Errorf("expected Nationality=nil, got %v", *res.Nationality)
 	}
 }
 
-// mustHostPort возвращает хост и порт из URL в виде строки.
-// Если URL некорректен или не содержит порт, функция вызывает панику.
-func mustHostPort(rawURL string) string {
-	u, err := url.Parse(rawURL)
+// TestComposite_Policies проверяет поведение CompositeEnricher при разных политиках, когда один провайдер падает.
+func TestComposite_Policies(t *testing.T) {
+	age := 42
+	newEnricher := func(policy Policy) *CompositeEnricher {
+		e := NewCompositeEnricher(
+			&stubProvider{name: "agify", field: FieldAge, res: &EnrichResult{Age: &age}},
+			&stubProvider{name: "nationalize", field: FieldNationality, err: ErrUpstreamUnavailable},
+		)
+		e.Policy = policy
+		return e
+	}
+
+	if _, err := newEnricher(PolicyStrict).Enrich(context.Background(), Query{Name: "ivan"}); err == nil {
+		t.Error("strict: expected error, got nil")
+	}
+
+	res, err := newEnricher(PolicyBestEffort).Enrich(context.Background(), Query{Name: "ivan"})
+	if err != nil {
+		t.Fatalf("best-effort: unexpected error: %v", err)
+	}
+	if res.Age == nil || *res.Age != 42 {
+		t.Errorf("best-effort: expected Age=42, got %v", res.Age)
+	}
+	if len(res.Missing) != 1 || res.Missing[0] != FieldNationality {
+		t.Errorf("best-effort: expected missing=[nationality], got %v", res.Missing)
+	}
+
+	res, err = newEnricher(PolicySkip).Enrich(context.Background(), Query{Name: "ivan"})
 	if err != nil {
-		panic(err)
+		t.Fatalf("skip: unexpected error: %v", err)
 	}
+	if res.Age != nil || len(res.Missing) != 2 {
+		t.Errorf("skip: expected no data and 2 missing fields, got %+v", res)
+	}
+}
+
+// TestEnrich_ForwardsCountry проверяет, что страна из запроса или страна по умолчанию передается провайдеру.
+func TestEnrich_ForwardsCountry(t *testing.T) {
+	var got []string
+	agify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Query().Get("country_id"))
+		fmt.Fprint(w, `{"age":40}`)
+	}))
+	defer agify.Close()
+
+	e := NewDefaultCountryEnricher(NewCompositeEnricher(&AgifyProvider{BaseURL: agify.URL}), "kz")
+
+	e.Enrich(context.Background(), Query{Name: "dmitriy", CountryID: "RU"})
+	e.Enrich(context.Background(), Query{Name: "dmitriy"})
+
+	if len(got) != 2 || got[0] != "RU" || got[1] != "KZ" {
+		t.Errorf("expected country_id [RU KZ], got %v", got)
+	}
+}
 
-	host, port, err := net.SplitHostPort(u.Host)
+// TestEnrich_Provenance проверяет, что для каждого поля сохраняются провайдер, идентификатор ответа
+// и исходный ответ, а при повторном запросе из кэша источником указывается кэш.
+func TestEnrich_Provenance(t *testing.T) {
+	agify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-42")
+		fmt.Fprint(w, `{"age":30,"count":1200}`)
+	}))
+	defer agify.Close()
+
+	e := NewCachedEnricher(NewCompositeEnricher(&AgifyProvider{BaseURL: agify.URL}), NewMemoryCache(10, time.Hour))
+
+	res, err := e.Enrich(context.Background(), Query{Name: "john"})
 	if err != nil {
-		panic(err)
+		t.Fatalf("Enrich returned error: %v", err)
+	}
+	fp, ok := res.Provenance[FieldAge]
+	if !ok {
+		t.Fatalf("expected provenance for age, got %+v", res.Provenance)
+	}
+	if fp.Source != SourceAPI || fp.Provider != "agify" || fp.ResponseID != "req-42" || !fp.Inferred {
+		t.Errorf("unexpected provenance: %+v", fp)
+	}
+	var raw agifyResponse
+	if err := json.Unmarshal(fp.Raw, &raw); err != nil || raw.Count == nil || *raw.Count != 1200 {
+		t.Errorf("expected raw agify payload, got %s", fp.Raw)
 	}
 
-	return net.JoinHostPort(host, port)
+	cached, err := e.Enrich(context.Background(), Query{Name: "john"})
+	if err != nil {
+		t.Fatalf("Enrich returned error: %v", err)
+	}
+	if got := cached.Provenance[FieldAge]; got.Source != "cache" || got.Provider != "agify" {
+		t.Errorf("expected cache source for cached result, got %+v", got)
+	}
+	if res.Provenance[FieldAge].Source != SourceAPI {
+		t.Error("expected cached entry to stay unchanged")
+	}
 }
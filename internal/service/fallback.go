@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"effect/internal/model"
+)
+
+// Источники результата обогащения.
+const (
+	SourceAPI      = model.SourceAPI
+	SourceFallback = model.SourceFallback
+)
+
+//go:embed data/names.csv
+var dictionaryFS embed.FS
+
+// Dictionary — локальный словарь имен с типичными полом, возрастом и странами.
+// Используется FallbackEnricher, когда внешние API недоступны.
+type Dictionary struct {
+	// MaxLearned ограничивает число имен, добавляемых Learn; 0 означает отсутствие ограничения.
+	MaxLearned int
+
+	mu      sync.RWMutex
+	entries map[string]*EnrichResult
+	learned int
+}
+
+// NewDictionary создает пустой словарь.
+func NewDictionary() *Dictionary {
+	return &Dictionary{entries: make(map[string]*EnrichResult)}
+}
+
+// DefaultDictionary загружает словарь, встроенный в бинарный файл.
+func DefaultDictionary() (*Dictionary, error) {
+	f, err := dictionaryFS.Open("data/names.csv")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadDictionary(f)
+}
+
+// LoadDictionaryFile загружает словарь из CSV-файла.
+func LoadDictionaryFile(path string) (*Dictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadDictionary(f)
+}
+
+// LoadDictionary читает CSV с заголовком name,gender,gender_probability,age,countries,
+// где countries — список вида RU:0.62;UA:0.11. Пустые ячейки означают отсутствие данных.
+func LoadDictionary(r io.Reader) (*Dictionary, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 5
+	if _, err := cr.Read(); err != nil {
+		return nil, fmt.Errorf("read dictionary header: %w", err)
+	}
+
+	d := NewDictionary()
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return d, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := parseDictionaryRecord(rec)
+		if err != nil {
+			return nil, fmt.Errorf("dictionary entry %q: %w", rec[0], err)
+		}
+		d.entries[Query{Name: rec[0]}.Key()] = res
+	}
+}
+
+func parseDictionaryRecord(rec []string) (*EnrichResult, error) {
+	var res EnrichResult
+	if rec[1] != "" {
+		gender := rec[1]
+		res.Gender = &gender
+	}
+	if rec[2] != "" {
+		p, err := strconv.ParseFloat(rec[2], 64)
+		if err != nil {
+			return nil, err
+		}
+		res.GenderProbability = &p
+	}
+	if rec[3] != "" {
+		age, err := strconv.Atoi(rec[3])
+		if err != nil {
+			return nil, err
+		}
+		res.Age = &age
+	}
+	for _, c := range strings.Split(rec[4], ";") {
+		if c == "" {
+			continue
+		}
+		id, prob, ok := strings.Cut(c, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid country %q", c)
+		}
+		p, err := strconv.ParseFloat(prob, 64)
+		if err != nil {
+			return nil, err
+		}
+		res.NationalityCandidates = append(res.NationalityCandidates, model.NationalityCandidate{CountryID: id, Probability: p})
+	}
+	if len(res.NationalityCandidates) > 0 {
+		sort.SliceStable(res.NationalityCandidates, func(i, j int) bool {
+			return res.NationalityCandidates[i].Probability > res.NationalityCandidates[j].Probability
+		})
+		res.Nationality = &res.NationalityCandidates[0].CountryID
+	}
+	return &res, nil
+}
+
+// Lookup возвращает копию записи словаря для запроса q: сначала для имени в стране q.CountryID,
+// затем для имени без страны.
+func (d *Dictionary) Lookup(q Query) (*EnrichResult, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	e, ok := d.entries[q.Key()]
+	if !ok {
+		e, ok = d.entries[Query{Name: q.Name}.Key()]
+	}
+	if !ok {
+		return nil, false
+	}
+	res := *e
+	return &res, true
+}
+
+// Learn обновляет словарь полным результатом, полученным от внешних API для запроса q.
+// Запись хранится под ключом q.Key(), поэтому прогноз для страны не заменяет общий прогноз по имени;
+// переносятся только непустые поля, чтобы пустой ответ провайдера не стирал известные значения.
+// Новые имена сверх MaxLearned не запоминаются.
+func (d *Dictionary) Learn(q Query, res *EnrichResult) {
+	if res == nil || len(res.Missing) > 0 || res.Source == SourceFallback {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := q.Key()
+	entry := &EnrichResult{}
+	if old, ok := d.entries[key]; ok {
+		copied := *old
+		entry = &copied
+	} else if d.MaxLearned > 0 && d.learned >= d.MaxLearned {
+		return
+	} else {
+		d.learned++
+	}
+	for _, field := range []string{FieldAge, FieldGender, FieldNationality} {
+		fillField(entry, res, field)
+	}
+	d.entries[key] = entry
+}
+
+// Len возвращает количество имен в словаре.
+func (d *Dictionary) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.entries)
+}
+
+// FallbackEnricher обращается к Primary, а при его ошибке или неполном результате
+// берет недостающие данные из локального словаря. Поля, взятые из словаря,
+// перечисляются в FallbackFields, а Source выставляется в SourceFallback.
+type FallbackEnricher struct {
+	Primary Enricher
+	Dict    *Dictionary
+	// Learn включает пополнение словаря успешными ответами Primary.
+	Learn bool
+}
+
+// NewFallbackEnricher оборачивает primary резервным словарем dict.
+func NewFallbackEnricher(primary Enricher, dict *Dictionary, learn bool) *FallbackEnricher {
+	return &FallbackEnricher{Primary: primary, Dict: dict, Learn: learn}
+}
+
+func (e *FallbackEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	res, err := e.Primary.Enrich(ctx, q)
+	return e.resolve(ctx, q, res, err)
+}
+
+// EnrichBatch обогащает запросы через Primary и при ошибке отвечает из словаря по каждому имени отдельно.
+// Если в словаре есть только часть имен, остальные возвращаются как *PartialError вместе с найденными
+// результатами, чтобы их обогащение повторили позже; если нет ни одного, возвращается исходная ошибка.
+func (e *FallbackEnricher) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	results, err := EnrichAll(ctx, e.Primary, queries)
+
+	out := make(map[Query]*EnrichResult, len(queries))
+	failed := make(map[Query]bool)
+	for _, q := range queries {
+		var res *EnrichResult
+		if err == nil {
+			res = results[q]
+		}
+		r, rerr := e.resolve(ctx, q, res, err)
+		switch {
+		case rerr != nil && ctx.Err() == nil && !errors.Is(rerr, context.Canceled):
+			failed[q] = true
+		case rerr != nil:
+			return nil, rerr
+		default:
+			out[q] = r
+		}
+	}
+	switch {
+	case len(failed) == 0:
+		return out, nil
+	case len(out) == 0:
+		return nil, err
+	}
+	return out, &PartialError{Err: err, Failed: failed}
+}
+
+// resolve дополняет результат Primary данными словаря.
+func (e *FallbackEnricher) resolve(ctx context.Context, q Query, res *EnrichResult, err error) (*EnrichResult, error) {
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		entry, ok := e.Dict.Lookup(q)
+		if !ok {
+			return nil, err
+		}
+		log.WithError(err).Warnf("service.FallbackEnricher: using offline dictionary for name=%s", q.Name)
+		entry.Source = SourceFallback
+		entry.FallbackFields = []string{FieldAge, FieldGender, FieldNationality}
+		entry.Provenance = model.Provenance{}
+		for _, field := range entry.FallbackFields {
+			entry.Provenance[field] = fallbackProvenance()
+		}
+		return entry, nil
+	}
+
+	if res == nil {
+		return nil, nil
+	}
+	if len(res.Missing) == 0 {
+		if res.Source == "" {
+			out := *res
+			out.Source = SourceAPI
+			res = &out
+		}
+		if e.Learn {
+			e.Dict.Learn(q, res)
+		}
+		return res, nil
+	}
+
+	entry, ok := e.Dict.Lookup(q)
+	if !ok {
+		return res, nil
+	}
+	out := *res
+	out.Missing = nil
+	out.Provenance = make(model.Provenance, len(res.Provenance)+len(res.Missing))
+	for field, fp := range res.Provenance {
+		out.Provenance[field] = fp
+	}
+	for _, field := range res.Missing {
+		if fillField(&out, entry, field) {
+			out.FallbackFields = append(out.FallbackFields, field)
+			out.Provenance[field] = fallbackProvenance()
+		} else {
+			out.Missing = append(out.Missing, field)
+		}
+	}
+	out.Source = SourceAPI
+	if len(out.FallbackFields) > 0 {
+		out.Source = SourceFallback
+		log.Infof("service.FallbackEnricher: filled %v for name=%s from offline dictionary", out.FallbackFields, q.Name)
+	}
+	return &out, nil
+}
+
+// fallbackProvenance описывает поле, взятое из локального словаря.
+func fallbackProvenance() model.FieldProvenance {
+	return model.FieldProvenance{Source: SourceFallback, Provider: "dictionary", Inferred: true, EnrichedAt: time.Now().UTC()}
+}
+
+// fillField переносит поле field из src в dst; возвращает false, если в src его нет.
+func fillField(dst, src *EnrichResult, field string) bool {
+	switch field {
+	case FieldAge:
+		if src.Age == nil {
+			return false
+		}
+		dst.Age, dst.AgeCount = src.Age, src.AgeCount
+	case FieldGender:
+		if src.Gender == nil {
+			return false
+		}
+		dst.Gender, dst.GenderProbability, dst.GenderCount = src.Gender, src.GenderProbability, src.GenderCount
+	case FieldNationality:
+		if src.Nationality == nil {
+			return false
+		}
+		dst.Nationality, dst.NationalityCandidates, dst.NationalityCount = src.Nationality, src.NationalityCandidates, src.NationalityCount
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestDefaultDictionary проверяет, что встроенный словарь загружается и содержит распространенные имена.
+func TestDefaultDictionary(t *testing.T) {
+	dict, err := DefaultDictionary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res, ok := dict.Lookup(Query{Name: "Dmitriy"})
+	if !ok {
+		t.Fatal("expected dmitriy in the embedded dictionary")
+	}
+	if res.Gender == nil || *res.Gender != "male" || res.Age == nil || res.Nationality == nil || *res.Nationality != "RU" {
+		t.Errorf("unexpected entry: %+v", res)
+	}
+}
+
+// TestFallbackEnricher_UsesDictionaryOnError проверяет, что при ошибке API результат берется из словаря
+// и помечается источником fallback, а неизвестное имя возвращает исходную ошибку.
+func TestFallbackEnricher_UsesDictionaryOnError(t *testing.T) {
+	dict, err := LoadDictionary(strings.NewReader("name,gender,gender_probability,age,countries\nanna,female,0.98,45,UA:0.07;PL:0.1\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e := NewFallbackEnricher(&stubProvider{err: ErrUpstreamUnavailable}, dict, true)
+
+	res, err := e.Enrich(context.Background(), Query{Name: "Anna"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Source != SourceFallback || len(res.FallbackFields) != 3 {
+		t.Errorf("expected fallback source for all fields, got %q %v", res.Source, res.FallbackFields)
+	}
+	if res.Nationality == nil || *res.Nationality != "PL" {
+		t.Errorf("expected most probable country PL, got %v", res.Nationality)
+	}
+
+	if _, err := e.Enrich(context.Background(), Query{Name: "zebulon"}); err != ErrUpstreamUnavailable {
+		t.Errorf("expected upstream error for unknown name, got %v", err)
+	}
+}
+
+// TestFallbackEnricher_BatchResolvesEachName проверяет, что при ошибке API пакет отвечает из словаря
+// по каждому имени, а неизвестные имена возвращаются в PartialError независимо от соседей по пакету.
+func TestFallbackEnricher_BatchResolvesEachName(t *testing.T) {
+	dict, err := LoadDictionary(strings.NewReader("name,gender,gender_probability,age,countries\naleksandr,male,0.99,41,RU:0.8\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fallback := NewFallbackEnricher(&stubProvider{err: ErrUpstreamUnavailable}, dict, false)
+	e := NewThresholdEnricher(NewNormalizingEnricher(fallback, TranslitNone), Thresholds{})
+
+	known, unknown := Query{Name: "Aleksandr"}, Query{Name: "Zebulon"}
+	results, err := EnrichAll(context.Background(), e, []Query{known, unknown})
+	partial, ok := AsPartial(err)
+	if !ok || !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("expected partial upstream error, got %v", err)
+	}
+	if !partial.Failed[unknown] || partial.Failed[known] {
+		t.Errorf("expected only unknown name failed, got %v", partial.Failed)
+	}
+	if res := results[known]; res == nil || res.Source != SourceFallback || res.Age == nil || *res.Age != 41 {
+		t.Errorf("expected fallback result for known name, got %+v", res)
+	}
+	if res := results[unknown]; res != nil {
+		t.Errorf("expected no result for unknown name, got %+v", res)
+	}
+
+	if _, err := e.EnrichBatch(context.Background(), []Query{unknown}); err != ErrUpstreamUnavailable {
+		t.Errorf("expected upstream error when no name is resolved, got %v", err)
+	}
+}
+
+// TestFallbackEnricher_FillsMissingAndLearns проверяет дозаполнение недостающих полей из словаря
+// и пополнение словаря полными ответами API.
+func TestFallbackEnricher_FillsMissingAndLearns(t *testing.T) {
+	dict := NewDictionary()
+	age, gender, nationality := 33, "male", "KZ"
+	primary := &stubProvider{res: &EnrichResult{Age: &age, Gender: &gender, Nationality: &nationality}}
+	e := NewFallbackEnricher(primary, dict, true)
+
+	res, err := e.Enrich(context.Background(), Query{Name: "timur"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Source != SourceAPI || dict.Len() != 1 {
+		t.Fatalf("expected api result to be learned, got source=%q len=%d", res.Source, dict.Len())
+	}
+
+	primary.res = &EnrichResult{Gender: &gender, Missing: []string{FieldAge, FieldNationality}}
+	res, err = e.Enrich(context.Background(), Query{Name: "timur"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Age == nil || *res.Age != 33 || res.Nationality == nil || *res.Nationality != "KZ" {
+		t.Errorf("expected missing fields to be filled from dictionary, got %+v", res)
+	}
+	if res.Source != SourceFallback || len(res.FallbackFields) != 2 || len(res.Missing) != 0 {
+		t.Errorf("unexpected provenance: source=%q fallback=%v missing=%v", res.Source, res.FallbackFields, res.Missing)
+	}
+}
+
+// TestDictionary_Learn проверяет, что прогноз для страны не заменяет общий, пустые поля ответа
+// не стирают известные значения, а новые имена сверх MaxLearned не запоминаются.
+func TestDictionary_Learn(t *testing.T) {
+	dict, err := LoadDictionary(strings.NewReader("name,gender,gender_probability,age,countries\nanna,female,0.98,45,PL:0.1\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dict.MaxLearned = 1
+	age, ua := 30, "UA"
+
+	dict.Learn(Query{Name: "Anna", CountryID: "UA"}, &EnrichResult{Age: &age, Nationality: &ua})
+	if res, _ := dict.Lookup(Query{Name: "anna"}); *res.Age != 45 || *res.Nationality != "PL" {
+		t.Errorf("country result must not replace the global entry, got %+v", res)
+	}
+	if res, _ := dict.Lookup(Query{Name: "anna", CountryID: "ua"}); *res.Age != 30 || res.Gender != nil {
+		t.Errorf("expected country entry, got %+v", res)
+	}
+
+	dict.Learn(Query{Name: "anna"}, &EnrichResult{Age: &age})
+	if res, _ := dict.Lookup(Query{Name: "anna"}); *res.Age != 30 || res.Gender == nil || *res.Gender != "female" {
+		t.Errorf("expected age updated and gender kept, got %+v", res)
+	}
+
+	dict.Learn(Query{Name: "timur"}, &EnrichResult{Age: &age})
+	if _, ok := dict.Lookup(Query{Name: "timur"}); ok || dict.Len() != 2 {
+		t.Errorf("expected new names over MaxLearned to be ignored, len=%d", dict.Len())
+	}
+}
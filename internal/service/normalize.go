@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TranslitScheme — схема транслитерации имен в латиницу перед обращением к провайдерам.
+type TranslitScheme string
+
+const (
+	// TranslitNone отключает транслитерацию: имя только обрезается и приводится к единому регистру.
+	TranslitNone TranslitScheme = "none"
+	// TranslitBGN — BGN/PCGN без апострофов: Дмитрий → Dmitriy, Юлия → Yuliya.
+	TranslitBGN TranslitScheme = "bgn"
+	// TranslitPassport — схема загранпаспортов РФ (ICAO Doc 9303): Дмитрий → Dmitrii, Юлия → Iuliia.
+	TranslitPassport TranslitScheme = "passport"
+)
+
+// ParseTranslitScheme разбирает название схемы транслитерации.
+func ParseTranslitScheme(s string) (TranslitScheme, error) {
+	switch t := TranslitScheme(s); t {
+	case TranslitNone, TranslitBGN, TranslitPassport:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown transliteration scheme %q", s)
+}
+
+// translitBGN — таблица BGN/PCGN для кириллицы (русский, украинский, белорусский, казахский алфавиты)
+// и ELOT 743 для греческого. Мягкий и твердый знаки опускаются.
+var translitBGN = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "ye", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "w",
+	'ә': "a", 'ғ': "gh", 'қ': "q", 'ң': "ng", 'ө': "o", 'ұ': "u", 'ү': "u", 'һ': "h",
+
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o", 'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+	'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+}
+
+// translitPassport отличается от BGN/PCGN только перечисленными буквами.
+var translitPassport = withOverrides(translitBGN, map[rune]string{
+	'ё': "e", 'й': "i", 'ъ': "ie", 'ю': "iu", 'я': "ia", 'є': "ie", 'ї': "i",
+})
+
+func withOverrides(base, overrides map[rune]string) map[rune]string {
+	out := make(map[rune]string, len(base))
+	for r, s := range base {
+		out[r] = s
+	}
+	for r, s := range overrides {
+		out[r] = s
+	}
+	return out
+}
+
+// NormalizeName обрезает пробелы, схлопывает повторяющиеся пробелы, транслитерирует имя
+// по схеме scheme и приводит каждую часть имени (в том числе после дефиса и апострофа)
+// к виду «Заглавная первая буква». Символы, отсутствующие в таблице схемы, не меняются.
+func NormalizeName(name string, scheme TranslitScheme) string {
+	var table map[rune]string
+	switch scheme {
+	case TranslitBGN:
+		table = translitBGN
+	case TranslitPassport:
+		table = translitPassport
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.Join(strings.Fields(name), " ")) {
+		if s, ok := table[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	out := []rune(b.String())
+	for i, r := range out {
+		if i == 0 || out[i-1] == ' ' || out[i-1] == '-' || out[i-1] == '\'' {
+			out[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(out)
+}
+
+// NormalizingEnricher передает провайдерам нормализованное и транслитерированное имя
+// (см. NormalizeName). Исходное написание имени остается у вызывающего кода.
+type NormalizingEnricher struct {
+	Next   Enricher
+	Scheme TranslitScheme
+}
+
+// NewNormalizingEnricher оборачивает next.
+func NewNormalizingEnricher(next Enricher, scheme TranslitScheme) *NormalizingEnricher {
+	return &NormalizingEnricher{Next: next, Scheme: scheme}
+}
+
+func (e *NormalizingEnricher) normalize(q Query) Query {
+	q.Name = NormalizeName(q.Name, e.Scheme)
+	return q
+}
+
+func (e *NormalizingEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	return e.Next.Enrich(ctx, e.normalize(q))
+}
+
+// EnrichBatch нормализует имена и возвращает результаты по исходным запросам.
+// Разные написания одного имени (например, «Дмитрий» и «Dmitriy») обогащаются один раз.
+func (e *NormalizingEnricher) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	normalized := make([]Query, len(queries))
+	for i, q := range queries {
+		normalized[i] = e.normalize(q)
+	}
+
+	results, err := EnrichAll(ctx, e.Next, normalized)
+	partial, ok := AsPartial(err)
+	if err != nil && !ok {
+		return nil, err
+	}
+
+	out := make(map[Query]*EnrichResult, len(queries))
+	for i, q := range queries {
+		out[q] = results[normalized[i]]
+	}
+	return out, partial.remap(queries, normalized)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	cases := []struct {
+		name   string
+		scheme TranslitScheme
+		want   string
+	}{
+		{"  дмитрий ", TranslitBGN, "Dmitriy"},
+		{"Дмитрий", TranslitPassport, "Dmitrii"},
+		{"ЮЛИЯ", TranslitBGN, "Yuliya"},
+		{"Юлия", TranslitPassport, "Iuliia"},
+		{"Щукин-Жуков", TranslitBGN, "Shchukin-Zhukov"},
+		{"Олесь", TranslitBGN, "Oles"},
+		{"Їжак", TranslitBGN, "Yizhak"},
+		{"Γιώργος", TranslitBGN, "Giorgos"},
+		{"anna  maria", TranslitBGN, "Anna Maria"},
+		{"o'BRIEN", TranslitBGN, "O'Brien"},
+		{" дмитрий ", TranslitNone, "Дмитрий"},
+	}
+	for _, c := range cases {
+		if got := NormalizeName(c.name, c.scheme); got != c.want {
+			t.Errorf("NormalizeName(%q, %s) = %q, want %q", c.name, c.scheme, got, c.want)
+		}
+	}
+}
+
+// recordingEnricher запоминает запросы и возвращает пустой результат.
+type recordingEnricher struct {
+	queries []Query
+}
+
+func (e *recordingEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	e.queries = append(e.queries, q)
+	return &EnrichResult{}, nil
+}
+
+// TestNormalizingEnricher_Batch проверяет, что провайдерам передаются транслитерированные имена,
+// а результаты возвращаются по исходным запросам.
+func TestNormalizingEnricher_Batch(t *testing.T) {
+	next := &recordingEnricher{}
+	e := NewNormalizingEnricher(next, TranslitBGN)
+
+	queries := []Query{{Name: "Дмитрий", CountryID: "RU"}, {Name: " anna "}}
+	results, err := e.EnrichBatch(context.Background(), queries)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Query{{Name: "Dmitriy", CountryID: "RU"}, {Name: "Anna"}}
+	if len(next.queries) != len(want) {
+		t.Fatalf("next got %v, want %v", next.queries, want)
+	}
+	for i := range want {
+		if next.queries[i] != want[i] {
+			t.Errorf("query %d = %+v, want %+v", i, next.queries[i], want[i])
+		}
+	}
+	for _, q := range queries {
+		if results[q] == nil {
+			t.Errorf("no result for original query %+v", q)
+		}
+	}
+}
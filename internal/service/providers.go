@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"effect/internal/model"
+)
+
+// Адреса публичных API, используемые по умолчанию.
+const (
+	DefaultAgifyURL       = "https://api.agify.io"
+	DefaultGenderizeURL   = "https://api.genderize.io"
+	DefaultNationalizeURL = "https://api.nationalize.io"
+)
+
+// singleURL строит адрес запроса для одного имени вида base/?name=a&country_id=RU&apikey=KEY.
+func singleURL(base, apiKey string, q Query) string {
+	v := url.Values{"name": {q.Name}}
+	if q.CountryID != "" {
+		v.Set("country_id", q.CountryID)
+	}
+	return buildURL(base, apiKey, v)
+}
+
+// batchURL строит адрес пакетного запроса вида base/?name[]=a&name[]=b&country_id=RU&apikey=KEY.
+// Страна берется из первого запроса: все запросы пакета имеют одинаковый CountryID.
+func batchURL(base, apiKey string, queries []Query) string {
+	v := url.Values{}
+	for _, q := range queries {
+		v.Add("name[]", q.Name)
+	}
+	if len(queries) > 0 && queries[0].CountryID != "" {
+		v.Set("country_id", queries[0].CountryID)
+	}
+	return buildURL(base, apiKey, v)
+}
+
+func buildURL(base, apiKey string, v url.Values) string {
+	if apiKey != "" {
+		v.Set("apikey", apiKey)
+	}
+	return strings.TrimSuffix(base, "/") + "/?" + v.Encode()
+}
+
+// providerResponse — ответ провайдера на запрос по одному имени.
+type providerResponse interface {
+	result() *EnrichResult
+}
+
+// fetchOne запрашивает данные по одному имени и помечает поле field как полученное от provider.
+func fetchOne[T providerResponse](ctx context.Context, c *APIClient, rawURL, provider, field string) (*EnrichResult, error) {
+	var resp apiResponse
+	if err := c.callAPI(ctx, rawURL, &resp); err != nil {
+		return nil, err
+	}
+
+	var v T
+	if err := resp.decode(&v); err != nil {
+		return nil, err
+	}
+	res := v.result()
+	res.Provenance = apiProvenance(field, provider, resp.ID, resp.Body)
+	return res, nil
+}
+
+// fetchBatch выполняет пакетный запрос; ответ — массив в порядке имен из queries.
+// Ответ другой длины считается ошибкой всего пакета: по нему нельзя сопоставить элементы с именами.
+func fetchBatch[T providerResponse](ctx context.Context, c *APIClient, rawURL, provider, field string, queries []Query) (map[Query]*EnrichResult, error) {
+	var resp apiResponse
+	if err := c.callAPI(ctx, rawURL, &resp); err != nil {
+		return nil, err
+	}
+	var items []json.RawMessage
+	if err := resp.decode(&items); err != nil {
+		return nil, err
+	}
+
+	if len(items) != len(queries) {
+		return nil, fmt.Errorf("%s: batch response has %d items for %d names", provider, len(items), len(queries))
+	}
+
+	out := make(map[Query]*EnrichResult, len(queries))
+	for i, q := range queries {
+		var v T
+		if err := json.Unmarshal(items[i], &v); err != nil {
+			return nil, err
+		}
+		res := v.result()
+		res.Provenance = apiProvenance(field, provider, resp.ID, items[i])
+		out[q] = res
+	}
+	return out, nil
+}
+
+// apiProvenance описывает поле field, полученное от provider в ответе raw.
+func apiProvenance(field, provider, responseID string, raw json.RawMessage) model.Provenance {
+	return model.Provenance{field: {
+		Source:     SourceAPI,
+		Provider:   provider,
+		ResponseID: responseID,
+		Raw:        raw,
+		Inferred:   true,
+		EnrichedAt: time.Now().UTC(),
+	}}
+}
+
+// AgifyProvider определяет возраст по имени через API Agify.
+type AgifyProvider struct {
+	BaseURL string
+	// APIKey передается параметром apikey для платных тарифов; пустое значение — бесплатный тариф.
+	APIKey string
+	Client *APIClient
+}
+
+type agifyResponse struct {
+	Age   *int `json:"age"`
+	Count *int `json:"count"`
+}
+
+func (a agifyResponse) result() *EnrichResult {
+	return &EnrichResult{Age: a.Age, AgeCount: a.Count}
+}
+
+// NewAgifyProvider создает провайдер Agify с адресом по умолчанию.
+// Если client равен nil, используется клиент с настройками по умолчанию.
+func NewAgifyProvider(client *APIClient) *AgifyProvider {
+	return &AgifyProvider{BaseURL: DefaultAgifyURL, Client: client}
+}
+
+func (p *AgifyProvider) Name() string  { return "agify" }
+func (p *AgifyProvider) Field() string { return FieldAge }
+
+func (p *AgifyProvider) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	url := singleURL(p.BaseURL, p.APIKey, q)
+	log.Debugf("service.AgifyProvider: calling Agify API: %s", safeURL(url))
+
+	res, err := fetchOne[agifyResponse](ctx, clientOrDefault(p.Client), url, p.Name(), p.Field())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("service.AgifyProvider: Agify result: %v", res.Age)
+	return res, nil
+}
+
+// EnrichBatch запрашивает возраст для нескольких имен (не более MaxBatchSize) одним запросом.
+// Все запросы пакета должны иметь одинаковый CountryID.
+func (p *AgifyProvider) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	return fetchBatch[agifyResponse](ctx, clientOrDefault(p.Client), batchURL(p.BaseURL, p.APIKey, queries), p.Name(), p.Field(), queries)
+}
+
+// GenderizeProvider определяет пол по имени через API Genderize.
+type GenderizeProvider struct {
+	BaseURL string
+	// APIKey передается параметром apikey для платных тарифов; пустое значение — бесплатный тариф.
+	APIKey string
+	Client *APIClient
+}
+
+type genderizeResponse struct {
+	Gender      *string  `json:"gender"`
+	Probability *float64 `json:"probability"`
+	Count       *int     `json:"count"`
+}
+
+func (g genderizeResponse) result() *EnrichResult {
+	return &EnrichResult{Gender: g.Gender, GenderProbability: g.Probability, GenderCount: g.Count}
+}
+
+// NewGenderizeProvider создает провайдер Genderize с адресом по умолчанию.
+// Если client равен nil, используется клиент с настройками по умолчанию.
+func NewGenderizeProvider(client *APIClient) *GenderizeProvider {
+	return &GenderizeProvider{BaseURL: DefaultGenderizeURL, Client: client}
+}
+
+func (p *GenderizeProvider) Name() string  { return "genderize" }
+func (p *GenderizeProvider) Field() string { return FieldGender }
+
+func (p *GenderizeProvider) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	url := singleURL(p.BaseURL, p.APIKey, q)
+	log.Debugf("service.GenderizeProvider: calling Genderize API: %s", safeURL(url))
+
+	res, err := fetchOne[genderizeResponse](ctx, clientOrDefault(p.Client), url, p.Name(), p.Field())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("service.GenderizeProvider: Genderize result: %v", res.Gender)
+	return res, nil
+}
+
+// EnrichBatch запрашивает пол для нескольких имен (не более MaxBatchSize) одним запросом.
+// Все запросы пакета должны иметь одинаковый CountryID.
+func (p *GenderizeProvider) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	return fetchBatch[genderizeResponse](ctx, clientOrDefault(p.Client), batchURL(p.BaseURL, p.APIKey, queries), p.Name(), p.Field(), queries)
+}
+
+// NationalizeProvider определяет национальность по имени через API Nationalize.
+// В Nationality попадает страна с наибольшей вероятностью, полный список — в NationalityCandidates.
+type NationalizeProvider struct {
+	BaseURL string
+	// APIKey передается параметром apikey для платных тарифов; пустое значение — бесплатный тариф.
+	APIKey string
+	Client *APIClient
+}
+
+type nationalizeResponse struct {
+	Country model.NationalityCandidates `json:"country"`
+	Count   *int                        `json:"count"`
+}
+
+func (n nationalizeResponse) result() *EnrichResult {
+	res := EnrichResult{NationalityCount: n.Count}
+	if len(n.Country) > 0 {
+		res.Nationality = &n.Country[0].CountryID
+		res.NationalityCandidates = n.Country
+	}
+	return &res
+}
+
+// NewNationalizeProvider создает провайдер Nationalize с адресом по умолчанию.
+// Если client равен nil, используется клиент с настройками по умолчанию.
+func NewNationalizeProvider(client *APIClient) *NationalizeProvider {
+	return &NationalizeProvider{BaseURL: DefaultNationalizeURL, Client: client}
+}
+
+func (p *NationalizeProvider) Name() string  { return "nationalize" }
+func (p *NationalizeProvider) Field() string { return FieldNationality }
+
+func (p *NationalizeProvider) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	url := singleURL(p.BaseURL, p.APIKey, q)
+	log.Debugf("service.NationalizeProvider: calling Nationalize API: %s", safeURL(url))
+
+	res, err := fetchOne[nationalizeResponse](ctx, clientOrDefault(p.Client), url, p.Name(), p.Field())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("service.NationalizeProvider: Nationalize result: %v", res.Nationality)
+	return res, nil
+}
+
+// EnrichBatch запрашивает национальность для нескольких имен (не более MaxBatchSize) одним запросом.
+// Все запросы пакета должны иметь одинаковый CountryID.
+func (p *NationalizeProvider) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	return fetchBatch[nationalizeResponse](ctx, clientOrDefault(p.Client), batchURL(p.BaseURL, p.APIKey, queries), p.Name(), p.Field(), queries)
+}
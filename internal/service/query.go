@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"strings"
+)
+
+// Query — запрос на обогащение одного имени.
+type Query struct {
+	Name string
+	// CountryID — код страны ISO 3166-1 alpha-2, уточняющий прогноз провайдеров.
+	// Пустое значение означает прогноз по всем странам.
+	CountryID string
+}
+
+// normalized возвращает запрос с обрезанным именем в нижнем регистре и кодом страны в верхнем.
+func (q Query) normalized() Query {
+	return Query{
+		Name:      strings.ToLower(strings.TrimSpace(q.Name)),
+		CountryID: strings.ToUpper(strings.TrimSpace(q.CountryID)),
+	}
+}
+
+// Key возвращает нормализованный ключ запроса для кэша и дедупликации.
+func (q Query) Key() string {
+	n := q.normalized()
+	if n.CountryID == "" {
+		return n.Name
+	}
+	return n.Name + "@" + n.CountryID
+}
+
+// DefaultCountryEnricher подставляет страну по умолчанию в запросы без CountryID.
+type DefaultCountryEnricher struct {
+	Next      Enricher
+	CountryID string
+}
+
+// NewDefaultCountryEnricher оборачивает next; если country пустая, запросы передаются без изменений.
+func NewDefaultCountryEnricher(next Enricher, country string) *DefaultCountryEnricher {
+	return &DefaultCountryEnricher{Next: next, CountryID: strings.ToUpper(strings.TrimSpace(country))}
+}
+
+func (e *DefaultCountryEnricher) withDefault(q Query) Query {
+	if q.CountryID == "" {
+		q.CountryID = e.CountryID
+	}
+	return q
+}
+
+func (e *DefaultCountryEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	return e.Next.Enrich(ctx, e.withDefault(q))
+}
+
+// EnrichBatch подставляет страну по умолчанию и возвращает результаты по исходным запросам.
+func (e *DefaultCountryEnricher) EnrichBatch(ctx context.Context, queries []Query) (map[Query]*EnrichResult, error) {
+	resolved := make([]Query, len(queries))
+	for i, q := range queries {
+		resolved[i] = e.withDefault(q)
+	}
+
+	results, err := EnrichAll(ctx, e.Next, resolved)
+	partial, ok := AsPartial(err)
+	if err != nil && !ok {
+		return nil, err
+	}
+
+	out := make(map[Query]*EnrichResult, len(queries))
+	for i, q := range queries {
+		out[q] = results[resolved[i]]
+	}
+	return out, partial.remap(queries, resolved)
+}
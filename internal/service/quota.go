@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrQuotaExhausted возвращается без обращения к провайдеру, если его квота исчерпана.
+// Является частным случаем ErrRateLimited.
+var ErrQuotaExhausted = fmt.Errorf("%w: quota exhausted", ErrRateLimited)
+
+// quotaWarnRatio — доля оставшейся квоты, при которой в лог пишется предупреждение.
+const quotaWarnRatio = 0.1
+
+// QuotaError описывает отказ из-за исчерпанной квоты провайдера.
+type QuotaError struct {
+	Provider string
+	ResetAt  time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: quota exhausted until %s", e.Provider, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuotaExhausted }
+
+// QuotaResetAt возвращает время восстановления квоты, если err вызвана исчерпанной квотой.
+func QuotaResetAt(err error) (time.Time, bool) {
+	var qe *QuotaError
+	if errors.As(err, &qe) {
+		return qe.ResetAt, true
+	}
+	return time.Time{}, false
+}
+
+// QuotaStatus — снимок состояния квоты провайдера для служебных эндпоинтов.
+type QuotaStatus struct {
+	Provider string `json:"provider"`
+	// Known равен false, пока провайдер не сообщил квоту в заголовках ответа.
+	Known     bool       `json:"known"`
+	Limit     int        `json:"limit"`
+	Remaining int        `json:"remaining"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+	Exhausted bool       `json:"exhausted"`
+}
+
+// Quota отслеживает оставшуюся квоту провайдера по заголовкам X-Rate-Limit-Limit,
+// X-Rate-Limit-Remaining и X-Rate-Limit-Reset (секунды до сброса) его ответов.
+type Quota struct {
+	Provider string
+
+	mu        sync.Mutex
+	known     bool
+	limit     int
+	remaining int
+	resetAt   time.Time
+	warned    bool
+
+	// now используется для подмены времени в тестах.
+	now func() time.Time
+}
+
+// NewQuota создает трекер квоты провайдера provider.
+func NewQuota(provider string) *Quota {
+	return &Quota{Provider: provider, now: time.Now}
+}
+
+// Update обновляет квоту по заголовкам ответа; ответы без заголовков квоты игнорируются.
+func (q *Quota) Update(h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-Rate-Limit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(h.Get("X-Rate-Limit-Limit"))
+	reset, _ := strconv.Atoi(h.Get("X-Rate-Limit-Reset"))
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	resetAt := q.now().Add(time.Duration(reset) * time.Second)
+	if remaining > q.remaining {
+		// квота восстановилась: предупреждение снова разрешено
+		q.warned = false
+	}
+	q.known, q.limit, q.remaining, q.resetAt = true, limit, remaining, resetAt
+
+	switch {
+	case remaining <= 0:
+		log.Errorf("service.Quota: %s quota exhausted, calls suspended until %s", q.Provider, resetAt.Format(time.RFC3339))
+	case !q.warned && limit > 0 && float64(remaining) <= float64(limit)*quotaWarnRatio:
+		q.warned = true
+		log.Warnf("service.Quota: %s quota is running low: %d of %d left, resets at %s",
+			q.Provider, remaining, limit, resetAt.Format(time.RFC3339))
+	}
+}
+
+// Allow возвращает *QuotaError, если квота исчерпана и период еще не сброшен.
+func (q *Quota) Allow() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.known || q.remaining > 0 {
+		return nil
+	}
+	if !q.now().Before(q.resetAt) {
+		// период сброшен: квота неизвестна до следующего ответа
+		q.known, q.warned = false, false
+		return nil
+	}
+	return &QuotaError{Provider: q.Provider, ResetAt: q.resetAt}
+}
+
+// Status возвращает снимок состояния квоты.
+func (q *Quota) Status() QuotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	st := QuotaStatus{Provider: q.Provider, Known: q.known}
+	if !q.known {
+		return st
+	}
+	resetAt := q.resetAt
+	st.Limit, st.Remaining, st.ResetAt = q.limit, q.remaining, &resetAt
+	st.Exhausted = q.remaining <= 0 && q.now().Before(q.resetAt)
+	return st
+}
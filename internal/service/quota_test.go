@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestQuota_StopsCallsUntilReset проверяет, что после ответа с X-Rate-Limit-Remaining: 0
+// провайдер не вызывается до сброса квоты, а ошибка сообщает время сброса.
+func TestQuota_StopsCallsUntilReset(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-Rate-Limit-Limit", "100")
+		w.Header().Set("X-Rate-Limit-Remaining", "0")
+		w.Header().Set("X-Rate-Limit-Reset", "3600")
+		fmt.Fprint(w, `{"age":30}`)
+	}))
+	defer srv.Close()
+
+	c := instantClient(nil)
+	c.Quota = NewQuota("agify")
+
+	var out struct{ Age int }
+	if err := c.callAPI(context.Background(), srv.URL, &out); err != nil {
+		t.Fatalf("first call returned error: %v", err)
+	}
+
+	err := c.callAPI(context.Background(), srv.URL, &out)
+	if !errors.Is(err, ErrQuotaExhausted) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected quota exhausted error, got %v", err)
+	}
+	if resetAt, ok := QuotaResetAt(err); !ok || time.Until(resetAt) < 59*time.Minute {
+		t.Errorf("expected reset in about an hour, got %v", resetAt)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected provider to be called once, got %d", n)
+	}
+
+	st := c.Quota.Status()
+	if !st.Known || !st.Exhausted || st.Limit != 100 || st.Remaining != 0 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+}
+
+// TestQuota_ResetsAfterPeriod проверяет, что по истечении периода вызовы снова разрешены.
+func TestQuota_ResetsAfterPeriod(t *testing.T) {
+	now := time.Now()
+	q := NewQuota("genderize")
+	q.now = func() time.Time { return now }
+	q.Update(http.Header{"X-Rate-Limit-Remaining": {"0"}, "X-Rate-Limit-Reset": {"60"}})
+
+	if err := q.Allow(); err == nil {
+		t.Fatal("expected calls to be suspended")
+	}
+	now = now.Add(61 * time.Second)
+	if err := q.Allow(); err != nil {
+		t.Errorf("expected calls to resume after reset, got %v", err)
+	}
+	if q.Status().Known {
+		t.Error("expected quota to be unknown until the next response")
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Thresholds задает минимальную достоверность, при которой обогащенное значение принимается.
+// Нулевое значение порога отключает соответствующую проверку.
+type Thresholds struct {
+	MinAgeCount               int
+	MinGenderProbability      float64
+	MinGenderCount            int
+	MinNationalityProbability float64
+	MinNationalityCount       int
+}
+
+// Apply возвращает копию res, в которой значения ниже порогов обнулены
+// и перечислены в LowConfidence. Вероятности и размеры выборок сохраняются,
+// чтобы было видно, почему значение отклонено. Если провайдер не сообщил
+// вероятность или размер выборки, соответствующая проверка пропускается.
+func (t Thresholds) Apply(res *EnrichResult) *EnrichResult {
+	if res == nil {
+		return nil
+	}
+	out := *res
+	out.LowConfidence = append([]string(nil), res.LowConfidence...)
+
+	if out.Age != nil && out.AgeCount != nil && *out.AgeCount < t.MinAgeCount {
+		out.Age = nil
+		out.LowConfidence = append(out.LowConfidence, FieldAge)
+	}
+
+	if out.Gender != nil {
+		low := (out.GenderProbability != nil && *out.GenderProbability < t.MinGenderProbability) ||
+			(out.GenderCount != nil && *out.GenderCount < t.MinGenderCount)
+		if low {
+			out.Gender = nil
+			out.LowConfidence = append(out.LowConfidence, FieldGender)
+		}
+	}
+
+	if out.Nationality != nil {
+		low := (len(out.NationalityCandidates) > 0 && out.NationalityCandidates[0].Probability < t.MinNationalityProbability) ||
+			(out.NationalityCount != nil && *out.NationalityCount < t.MinNationalityCount)
+		if low {
+			out.Nationality = nil
+			out.LowConfidence = append(out.LowConfidence, FieldNationality)
+		}
+	}
+
+	return &out
+}
+
+// ThresholdEnricher отбрасывает значения с низкой достоверностью из результатов Next.
+type ThresholdEnricher struct {
+	Next       Enricher
+	Thresholds Thresholds
+}
+
+// NewThresholdEnricher оборачивает next проверкой порогов достоверности.
+func NewThresholdEnricher(next Enricher, t Thresholds) *ThresholdEnricher {
+	return &ThresholdEnricher{Next: next, Thresholds: t}
+}
+
+func (e *ThresholdEnricher) Enrich(ctx context.Context, q Query) (*EnrichResult, error) {
+	res, err := e.Next.Enrich(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	out := e.Thresholds.Apply(res)
+	if len(out.LowConfidence) > 0 {
+		log.Infof("service.ThresholdEnricher: low-confidence fields for name=%s: %v", q.Name, out.LowConfidence)
+	}
+	return out, nil
+}
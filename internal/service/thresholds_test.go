@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+
+	"effect/internal/model"
+)
+
+// TestThresholds_Apply проверяет, что значения ниже порогов обнуляются и помечаются как low-confidence,
+// а исходный результат (например, из кэша) не изменяется.
+func TestThresholds_Apply(t *testing.T) {
+	age, ageCount := 30, 12
+	gender, genderProb, genderCount := "male", 0.65, 5000
+	nationality := "RU"
+	res := &EnrichResult{
+		Age: &age, AgeCount: &ageCount,
+		Gender: &gender, GenderProbability: &genderProb, GenderCount: &genderCount,
+		Nationality:           &nationality,
+		NationalityCandidates: []model.NationalityCandidate{{CountryID: "RU", Probability: 0.45}},
+	}
+
+	out := Thresholds{
+		MinAgeCount:               100,
+		MinGenderProbability:      0.8,
+		MinGenderCount:            100,
+		MinNationalityProbability: 0.3,
+	}.Apply(res)
+
+	if out.Age != nil || out.Gender != nil {
+		t.Errorf("expected age and gender to be rejected, got age=%v gender=%v", out.Age, out.Gender)
+	}
+	if out.Nationality == nil || *out.Nationality != "RU" {
+		t.Errorf("expected nationality RU to pass, got %v", out.Nationality)
+	}
+	if len(out.LowConfidence) != 2 || out.LowConfidence[0] != FieldAge || out.LowConfidence[1] != FieldGender {
+		t.Errorf("expected low_confidence=[age gender], got %v", out.LowConfidence)
+	}
+	if out.GenderProbability == nil || *out.GenderProbability != 0.65 {
+		t.Errorf("expected gender probability to be kept, got %v", out.GenderProbability)
+	}
+	if res.Age == nil || res.Gender == nil || len(res.LowConfidence) != 0 {
+		t.Error("expected source result to stay unchanged")
+	}
+
+	nationalityCount := 7
+	res.NationalityCount = &nationalityCount
+	out = Thresholds{MinNationalityProbability: 0.3, MinNationalityCount: 50}.Apply(res)
+	if out.Nationality != nil || len(out.LowConfidence) != 1 || out.LowConfidence[0] != FieldNationality {
+		t.Errorf("expected nationality with small sample to be rejected, got %v low=%v", out.Nationality, out.LowConfidence)
+	}
+	if out.NationalityCount == nil || *out.NationalityCount != 7 {
+		t.Errorf("expected nationality count to be kept, got %v", out.NationalityCount)
+	}
+}
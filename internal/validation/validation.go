@@ -0,0 +1,177 @@
+// Package validation проверяет входные данные записей и описывает ошибки по полям,
+// чтобы фронтенд мог сопоставить их с полями формы.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"effect/internal/model"
+)
+
+// Коды ошибок полей.
+const (
+	CodeRequired     = "required"
+	CodeTooLong      = "too_long"
+	CodeInvalidChars = "invalid_characters"
+	CodeInvalidValue = "invalid_value"
+	CodeOutOfRange   = "out_of_range"
+	CodeInvalidType  = "invalid_type"
+	CodeUnknownField = "unknown_field"
+	CodeReadOnly     = "read_only"
+)
+
+// MaxNameLength — максимальная длина имени, фамилии и отчества в символах.
+const MaxNameLength = 100
+
+// FieldError — ошибка одного поля запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors — ошибки полей запроса; пустой список означает, что запрос корректен.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Add добавляет ошибку поля field с кодом code.
+func (e *Errors) Add(field, code, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Person проверяет имя, фамилию и отчество: имя и фамилия обязательны, длина не больше MaxNameLength,
+// допустимы буквы латиницы и кириллицы, дефис, апостроф и пробел между частями составного имени.
+// Пустое отчество трактуется как отсутствующее.
+func Person(p model.Person) Errors {
+	var errs Errors
+	checkName(&errs, "name", p.Name, true)
+	checkName(&errs, "surname", p.Surname, true)
+	if p.Patronymic != nil {
+		checkName(&errs, "patronymic", *p.Patronymic, false)
+	}
+	return errs
+}
+
+func checkName(errs *Errors, field, value string, required bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if required {
+			errs.Add(field, CodeRequired, "%s is required", field)
+		}
+		return
+	}
+	if utf8.RuneCountInString(value) > MaxNameLength {
+		errs.Add(field, CodeTooLong, "%s must be at most %d characters", field, MaxNameLength)
+		return
+	}
+	letters := 0
+	for _, r := range value {
+		switch {
+		case unicode.In(r, unicode.Latin, unicode.Cyrillic):
+			letters++
+		case r == '-' || r == '\'' || r == '’' || r == ' ':
+		default:
+			errs.Add(field, CodeInvalidChars, "%s contains invalid character %q: only Latin and Cyrillic letters, hyphen and apostrophe are allowed", field, r)
+			return
+		}
+	}
+	if letters == 0 {
+		errs.Add(field, CodeInvalidChars, "%s must contain letters", field)
+	}
+}
+
+// Decode разбирает JSON-объект из r в v. Поля, которых нет в v, возвращаются как Errors: поля записи
+// model.Person (id, locked_fields, enrichment_status и т. п.), которые клиент не может задать в этом
+// запросе, — с кодом CodeReadOnly, остальные — с кодом CodeUnknownField. Значение неверного типа
+// тоже возвращается как Errors, синтаксическая ошибка JSON — как есть.
+func Decode(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	known := jsonFields(reflect.TypeOf(v).Elem())
+	var errs Errors
+	for _, field := range slices.Sorted(maps.Keys(members)) {
+		switch {
+		case containsFold(known, field):
+		case containsFold(personFields, field):
+			errs.Add(field, CodeReadOnly, "field %q cannot be set", field)
+		default:
+			errs.Add(field, CodeUnknownField, "unknown field %q", field)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	err = json.Unmarshal(data, v)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		errs.Add(typeErr.Field, CodeInvalidType, "%s must be %s, got %s", typeErr.Field, jsonType(typeErr.Type.Kind().String()), typeErr.Value)
+		return errs
+	}
+	return err
+}
+
+// personFields — JSON-имена полей записи.
+var personFields = jsonFields(reflect.TypeOf(model.Person{}))
+
+// jsonFields возвращает JSON-имена полей структуры t, включая поля встроенных структур.
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous && f.Type.Kind() == reflect.Struct {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// containsFold сообщает, есть ли field в names без учета регистра, как сопоставляет поля encoding/json.
+func containsFold(names []string, field string) bool {
+	return slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(name, field) })
+}
+
+// jsonType переводит вид типа Go в название типа JSON для сообщения об ошибке.
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case kind == "slice", kind == "array":
+		return "an array"
+	}
+	return "an object"
+}
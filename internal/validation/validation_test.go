@@ -0,0 +1,76 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"effect/internal/model"
+)
+
+// TestPerson проверяет обязательность, длину и допустимые символы имени, фамилии и отчества.
+func TestPerson(t *testing.T) {
+	str := func(s string) *string { return &s }
+	cases := []struct {
+		name string
+		p    model.Person
+		want []string // поле:код
+	}{
+		{"latin", model.Person{Name: "Anna-Maria", Surname: "O'Neil"}, nil},
+		{"cyrillic", model.Person{Name: "Дмитрий", Surname: "Ушаков", Patronymic: str("Васильевич")}, nil},
+		{"compound and accents", model.Person{Name: "José", Surname: "de la Cruz", Patronymic: str("")}, nil},
+		{"typographic apostrophe", model.Person{Name: "Ivan", Surname: "D’Artagnan"}, nil},
+		{"required", model.Person{Name: "", Surname: "  "}, []string{"name:required", "surname:required"}},
+		{"too long", model.Person{Name: strings.Repeat("я", MaxNameLength+1), Surname: "Ivanov"}, []string{"name:too_long"}},
+		{"max length", model.Person{Name: strings.Repeat("я", MaxNameLength), Surname: "Ivanov"}, nil},
+		{"digits", model.Person{Name: "Ivan", Surname: "Ivanov", Patronymic: str("Petrovich2")}, []string{"patronymic:invalid_characters"}},
+		{"other script", model.Person{Name: "Γιώργος", Surname: "Ivanov"}, []string{"name:invalid_characters"}},
+		{"no letters", model.Person{Name: "--", Surname: "'"}, []string{"name:invalid_characters", "surname:invalid_characters"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, fe := range Person(c.p) {
+			got = append(got, fe.Field+":"+fe.Code)
+		}
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+// TestDecode проверяет, что неизвестные поля, поля записи только для чтения и неверные типы возвращаются как ошибки полей.
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		body, field, code, message string
+	}{
+		{`{"name":"Ivan","nickname":"Vanya"}`, "nickname", CodeUnknownField, `unknown field "nickname"`},
+		{`{"name":"Ivan","Locked_Fields":["age"]}`, "Locked_Fields", CodeReadOnly, `field "Locked_Fields" cannot be set`},
+		{`{"name":7}`, "name", CodeInvalidType, "name must be a string, got number"},
+		{`{"age":"old"}`, "age", CodeInvalidType, "age must be a number, got string"},
+	}
+	for _, c := range cases {
+		var p struct {
+			Name string `json:"name"`
+			Age  *int   `json:"age"`
+		}
+		err := Decode(strings.NewReader(c.body), &p)
+		var errs Errors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("%s: expected one field error, got %v", c.body, err)
+			continue
+		}
+		if fe := errs[0]; fe.Field != c.field || fe.Code != c.code || fe.Message != c.message {
+			t.Errorf("%s: unexpected error %+v", c.body, fe)
+		}
+	}
+
+	var p model.Person
+	err := Decode(strings.NewReader(`{invalid json}`), &p)
+	var errs Errors
+	if err == nil || errors.As(err, &errs) {
+		t.Errorf("expected syntax error, got %v", err)
+	}
+	if err := Decode(strings.NewReader(`{"name":"Ivan","surname":"Ivanov"}`), &p); err != nil || p.Name != "Ivan" {
+		t.Errorf("expected valid payload decoded, got %v, %+v", err, p)
+	}
+}
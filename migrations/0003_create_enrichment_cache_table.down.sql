@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS enrichment_cache_updated_at_idx;
+DROP TABLE IF EXISTS enrichment_cache;
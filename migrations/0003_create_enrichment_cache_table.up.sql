@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS enrichment_cache (
+    name TEXT PRIMARY KEY,
+    result JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS enrichment_cache_updated_at_idx
+  ON enrichment_cache (updated_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS persons_enrichment_missing_idx;
+ALTER TABLE persons
+  DROP COLUMN enrichment_missing;
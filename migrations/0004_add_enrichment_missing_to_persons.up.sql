@@ -0,0 +1,4 @@
+ALTER TABLE persons
+  ADD COLUMN enrichment_missing TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS persons_enrichment_missing_idx
+  ON persons (id) WHERE enrichment_missing <> '{}';
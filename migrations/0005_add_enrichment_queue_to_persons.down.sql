@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS persons_enrichment_pending_idx;
+ALTER TABLE persons
+  DROP COLUMN enrichment_error,
+  DROP COLUMN enrichment_next_at,
+  DROP COLUMN enrichment_attempts,
+  DROP COLUMN enrichment_status;
@@ -0,0 +1,7 @@
+ALTER TABLE persons
+  ADD COLUMN enrichment_status TEXT NOT NULL DEFAULT 'done',
+  ADD COLUMN enrichment_attempts INT NOT NULL DEFAULT 0,
+  ADD COLUMN enrichment_next_at TIMESTAMPTZ,
+  ADD COLUMN enrichment_error TEXT;
+CREATE INDEX IF NOT EXISTS persons_enrichment_pending_idx
+  ON persons (enrichment_next_at) WHERE enrichment_status = 'pending';
@@ -0,0 +1,5 @@
+ALTER TABLE persons
+  DROP COLUMN nationality_candidates,
+  DROP COLUMN gender_sample_count,
+  DROP COLUMN gender_probability,
+  DROP COLUMN age_sample_count;
@@ -0,0 +1,5 @@
+ALTER TABLE persons
+  ADD COLUMN age_sample_count INT,
+  ADD COLUMN gender_probability DOUBLE PRECISION,
+  ADD COLUMN gender_sample_count INT,
+  ADD COLUMN nationality_candidates JSONB;
@@ -0,0 +1,2 @@
+ALTER TABLE persons
+  DROP COLUMN enrichment_low_confidence;
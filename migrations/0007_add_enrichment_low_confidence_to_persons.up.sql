@@ -0,0 +1,2 @@
+ALTER TABLE persons
+  ADD COLUMN enrichment_low_confidence TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+ALTER TABLE persons
+  ADD COLUMN country_hint TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE persons
+  DROP COLUMN enrichment_fallback_fields,
+  DROP COLUMN enrichment_source;
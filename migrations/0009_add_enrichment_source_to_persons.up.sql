@@ -0,0 +1,3 @@
+ALTER TABLE persons
+  ADD COLUMN enrichment_source TEXT,
+  ADD COLUMN enrichment_fallback_fields TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS person_field_history (
+    id BIGSERIAL PRIMARY KEY,
+    person_id INT NOT NULL REFERENCES persons(id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    value TEXT,
+    source TEXT NOT NULL,
+    provider TEXT,
+    response_id TEXT,
+    raw JSONB,
+    inferred BOOLEAN NOT NULL,
+    enriched_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_person_field_history_person_field
+  ON person_field_history (person_id, field, id DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE persons
+  ADD COLUMN locked_fields TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS persons_enriched_at_idx;
+
+ALTER TABLE persons
+  DROP COLUMN enriched_at;
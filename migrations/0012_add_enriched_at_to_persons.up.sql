@@ -0,0 +1,8 @@
+ALTER TABLE persons
+  ADD COLUMN enriched_at TIMESTAMPTZ;
+
+UPDATE persons SET enriched_at = created_at WHERE enrichment_status = 'done';
+
+CREATE INDEX IF NOT EXISTS persons_enriched_at_idx
+  ON persons (enriched_at NULLS FIRST, id)
+  WHERE enrichment_status = 'done';
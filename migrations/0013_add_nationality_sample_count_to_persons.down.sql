@@ -0,0 +1,2 @@
+ALTER TABLE persons
+  DROP COLUMN nationality_sample_count;
@@ -0,0 +1,2 @@
+ALTER TABLE persons
+  ADD COLUMN nationality_sample_count INT;